@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/5amCurfew/xtkt/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// CommitState writes the last state a target echoed on input to the <stream_name>_state.json file of each
+// configured stream. Targets echo a STATE message's value (or the whole message) only once the records
+// before it are loaded. A last line in any other shape is an error.
+func CommitState(input io.Reader, streams []models.StreamConfig) error {
+	reader := bufio.NewReader(input)
+
+	var last []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			last = trimmed
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading target state: %w", err)
+		}
+	}
+
+	if last == nil {
+		log.Warn("target confirmed no state; state files not updated")
+		return nil
+	}
+
+	var confirmed models.TapState
+	if err := confirmed.Create(last); err != nil {
+		return fmt.Errorf("error parsing target state: %w", err)
+	}
+
+	configured := map[string]bool{}
+	for _, stream := range streams {
+		configured[stream.StreamName] = true
+	}
+	for name := range confirmed.Bookmarks {
+		if !configured[name] {
+			log.WithField("stream", name).Warn("confirmed state for stream not in config; not writing")
+			delete(confirmed.Bookmarks, name)
+		}
+	}
+
+	if len(confirmed.Bookmarks) == 0 {
+		log.Warn("target confirmed no state for any configured stream; state files not updated")
+		return nil
+	}
+
+	if err := confirmed.Update(); err != nil {
+		return fmt.Errorf("error writing confirmed state: %w", err)
+	}
+
+	for name := range confirmed.Bookmarks {
+		log.WithField("stream", name).Info("confirmed state written")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/5amCurfew/xtkt/models"
+	util "github.com/5amCurfew/xtkt/util"
+	"github.com/5amCurfew/xtkt/xtkt"
+	log "github.com/sirupsen/logrus"
+)
+
+// Options controls how RunStreams runs each stream
+type Options struct {
+	Discover bool
+	Refresh  bool
+
+	// Catalog and State are the Singer catalog and state supplied by a runner (--catalog, --state).
+	// With a Catalog, only streams present and selected in it are extracted.
+	Catalog *models.TapCatalog
+	State   *models.TapState
+
+	// WriteCatalog writes the discovered Singer catalog to output instead of SCHEMA messages
+	WriteCatalog bool
+
+	// ConfirmedState leaves state files to be written by CommitState from the state a target confirms
+	ConfirmedState bool
+
+	// ParallelStreams is the number of streams run at once (default DefaultParallelStreams)
+	ParallelStreams int
+}
+
+// DefaultParallelStreams bounds the sources and pipelines open at once when Options.ParallelStreams is unset
+const DefaultParallelStreams = 4
+
+// RunStreams runs discovery or extraction for every stream, up to Options.ParallelStreams at once, continuing
+// past failed streams. Each stream keeps its own catalog and state files; messages for every stream are
+// interleaved on output.
+func RunStreams(ctx context.Context, streams []models.StreamConfig, options Options, output io.Writer) error {
+	output = util.NewSyncWriter(output)
+
+	if options.Catalog != nil && !options.Discover {
+		streams = selectCatalogStreams(streams, options.Catalog)
+		if len(streams) == 0 {
+			log.Warn("no streams selected in catalog; nothing to extract")
+			return nil
+		}
+	}
+
+	runnerOutput := output
+	if options.Discover && options.WriteCatalog {
+		runnerOutput = io.Discard
+	}
+
+	// STATE messages carry every stream's state, starting from the supplied state so streams not run are kept
+	tapState := &models.TapState{Bookmarks: map[string]*models.StreamState{}}
+	if options.State != nil {
+		for name, state := range options.State.Bookmarks {
+			tapState.Bookmarks[name] = state
+		}
+	}
+
+	parallel := options.ParallelStreams
+	if parallel <= 0 {
+		parallel = DefaultParallelStreams
+	}
+	slots := make(chan struct{}, parallel)
+
+	runners := make([]*xtkt.Runner, len(streams))
+	errs := make([]error, len(streams))
+	var wg sync.WaitGroup
+	for i, stream := range streams {
+		runner := xtkt.NewRunner(stream, runnerOutput)
+		runner.FullRefresh = options.Refresh
+		runner.TapState = tapState
+		runner.ConfirmedState = options.ConfirmedState
+		if options.State != nil {
+			runner.InputState = options.State.Stream(stream.StreamName)
+		}
+		if options.Catalog != nil {
+			runner.InputCatalog, _, _ = options.Catalog.Lookup(stream.StreamName)
+		}
+		runners[i] = runner
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if options.Discover {
+				errs[i] = runners[i].Discover(ctx)
+			} else {
+				errs[i] = runners[i].Extract(ctx)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if options.Discover && options.WriteCatalog {
+		var discovered []models.StreamCatalog
+		for i, runner := range runners {
+			if errs[i] == nil {
+				discovered = append(discovered, runner.Catalog)
+			}
+		}
+
+		var catalog models.TapCatalog
+		if err := catalog.Create(discovered); err != nil {
+			return err
+		}
+		if err := catalog.Message(output); err != nil {
+			return fmt.Errorf("catalog message generation failed: %w", err)
+		}
+	}
+
+	if len(streams) == 1 {
+		return errs[0]
+	}
+
+	var failed []string
+	var failures []error
+	for i, err := range errs {
+		if err != nil {
+			log.WithFields(log.Fields{
+				"stream": streams[i].StreamName,
+				"error":  err,
+			}).Error("stream run failed")
+			failed = append(failed, streams[i].StreamName)
+			failures = append(failures, err)
+		}
+	}
+
+	// Joined so callers can still match xtkt.ErrConfig and xtkt.ErrSource from any stream
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d streams failed (%s): %w", len(failed), len(streams), strings.Join(failed, ", "), errors.Join(failures...))
+	}
+
+	return nil
+}
+
+// selectCatalogStreams keeps the streams present and selected in a Singer catalog
+func selectCatalogStreams(streams []models.StreamConfig, catalog *models.TapCatalog) []models.StreamConfig {
+	var selected []models.StreamConfig
+	for _, stream := range streams {
+		_, isSelected, found := catalog.Lookup(stream.StreamName)
+		if !found || !isSelected {
+			log.WithFields(log.Fields{
+				"stream":   stream.StreamName,
+				"found":    found,
+				"selected": isSelected,
+			}).Info("stream not selected in catalog; skipping")
+			continue
+		}
+		selected = append(selected, stream)
+	}
+	return selected
+}
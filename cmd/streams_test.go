@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+func TestRunStreamsWithParent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var results []interface{}
+		switch r.URL.Path {
+		case "/repos":
+			results = []interface{}{map[string]interface{}{"id": 1, "name": "a"}, map[string]interface{}{"id": 2, "name": "b"}}
+		case "/repos/a/commits":
+			results = []interface{}{map[string]interface{}{"sha": "a1"}, map[string]interface{}{"sha": "a2"}}
+		case "/repos/b/commits":
+			results = []interface{}{map[string]interface{}{"sha": "b1"}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	}))
+	defer server.Close()
+
+	// Runners read and write <stream_name>_state.json and _catalog.json in the working directory
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	configJSON := `{"streams": [
+		{"stream_name": "repos", "source_type": "rest", "url": "` + server.URL + `/repos",
+			"records": {"unique_key_path": ["id"]}, "rest": {"response": {"records_path": ["results"]}}},
+		{"stream_name": "commits", "source_type": "rest", "url": "` + server.URL + `/repos/{name}/commits",
+			"records": {"unique_key_path": ["sha"]},
+			"rest": {"response": {"records_path": ["results"]}, "parent": {"stream": "repos", "key_paths": {"name": ["name"]}, "parallel": 2}}}
+	]}`
+	if err := os.WriteFile("config.json", []byte(configJSON), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var config models.StreamsConfig
+	if err := config.Create("config.json"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var output bytes.Buffer
+	if err := RunStreams(context.Background(), config.Streams, Options{Discover: true}, &output); err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	output.Reset()
+	if err := RunStreams(context.Background(), config.Streams, Options{}, &output); err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+
+	records := map[string][]string{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var message struct {
+			Type   string                 `json:"type"`
+			Stream string                 `json:"stream"`
+			Record map[string]interface{} `json:"record"`
+		}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("invalid message %q: %v", line, err)
+		}
+		if message.Type != "RECORD" {
+			continue
+		}
+		if message.Stream == "commits" {
+			parent, _ := message.Record["_sdc_parent"].(map[string]interface{})
+			records["commits"] = append(records["commits"], message.Record["sha"].(string)+"/"+parent["name"].(string))
+		} else {
+			records[message.Stream] = append(records[message.Stream], message.Record["name"].(string))
+		}
+	}
+	for _, stream := range records {
+		sort.Strings(stream)
+	}
+
+	if got := strings.Join(records["repos"], ","); got != "a,b" {
+		t.Errorf("got repos %s, want a,b", got)
+	}
+	if got := strings.Join(records["commits"], ","); got != "a1/a,a2/a,b1/b" {
+		t.Errorf("got commits %s, want a1/a,a2/a,b1/b", got)
+	}
+}
@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"context"
 	"encoding/json"
 	"runtime"
 	"sync"
@@ -9,41 +10,93 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-var ExtractedChan = make(chan map[string]interface{}) // Unbuffered channel for extracted records; processing goroutines will read from this channel
-var ResultChan = make(chan models.Record, 100)        // Buffered channel to prevent blocking on writes when processing is slower than extraction
-var ProcessingWG sync.WaitGroup                       // WaitGroup to track processing goroutines
-var workerSem = make(chan struct{}, runtime.NumCPU()) // Concurrency cap keeps CPU-bound transforms from outnumbering cores
+const defaultResultBuffer = 100
+
+// SourceFunc streams raw records from a source onto records until the source is exhausted, fails or ctx is cancelled
+type SourceFunc func(ctx context.Context, config *models.StreamConfig, records chan<- map[string]interface{}) error
+
+// Pipeline owns the channels, worker pool and transformation metrics of a single stream extraction.
+// A new Pipeline is required for every run as its channels are closed once extraction completes.
+type Pipeline struct {
+	ExtractedChan chan map[string]interface{} // Unbuffered channel for extracted records; processing goroutines will read from this channel
+	ResultChan    chan models.Record          // Buffered channel to prevent blocking on writes when processing is slower than extraction
+	ProcessingWG  sync.WaitGroup              // WaitGroup to track processing goroutines
+	Metrics       *TransformationMetrics
+	Concurrency   int // Effective worker pool size
+
+	config      *models.StreamConfig
+	state       *models.StreamState
+	catalog     *models.StreamCatalog
+	fullRefresh bool
+	discover    bool
+	workerSem   chan struct{} // Concurrency cap keeps CPU-bound transforms from outnumbering cores, unless max_concurrency says otherwise
+}
+
+// NewPipeline creates a pipeline transforming records for config, filtering them against state and validating
+// them against catalog (except in discovery), whose schema must be compiled before records are extracted.
+// The worker pool defaults to one worker per CPU and the result buffer to 100 records unless configured.
+func NewPipeline(config *models.StreamConfig, state *models.StreamState, catalog *models.StreamCatalog, fullRefresh bool, discover bool) *Pipeline {
+	concurrency := config.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	resultBuffer := config.ResultBuffer
+	if resultBuffer <= 0 {
+		resultBuffer = defaultResultBuffer
+	}
+
+	return &Pipeline{
+		ExtractedChan: make(chan map[string]interface{}),
+		ResultChan:    make(chan models.Record, resultBuffer),
+		Metrics:       &TransformationMetrics{},
+		Concurrency:   concurrency,
+		config:        config,
+		state:         state,
+		catalog:       catalog,
+		fullRefresh:   fullRefresh,
+		discover:      discover,
+		workerSem:     make(chan struct{}, concurrency),
+	}
+}
 
 // ExtractRecords begins streaming records from source (sending to ExtractedChan) and start goroutines to extract records (sending to ResultChan)
-func ExtractRecords(sourceFunc func(*models.StreamConfig) error) {
+// Returns the source error, if any, once every extracted record has been handed to a worker
+func (p *Pipeline) ExtractRecords(ctx context.Context, sourceFunc SourceFunc) error {
+	var sourceErr error
+
 	// begin a goroutine to stream records from source
 	go func() {
-		defer close(ExtractedChan)
-		if err := sourceFunc(&models.Config); err != nil {
+		defer close(p.ExtractedChan)
+		if err := sourceFunc(ctx, p.config, p.ExtractedChan); err != nil {
 			log.WithFields(log.Fields{
 				"error":       err,
-				"source_type": models.Config.SourceType,
-				"url":         models.Config.URL,
+				"stream":      p.config.StreamName,
+				"source_type": p.config.SourceType,
+				"url":         p.config.URL,
 			}).Error("source extraction failed")
+			sourceErr = err
 		}
 	}()
 
 	// begin a goroutine for each extracted record, processing the record (sending to the ResultChan)
-	for record := range ExtractedChan {
-		ProcessingWG.Add(1)
-		workerSem <- struct{}{} // Block here when every core already runs a processing goroutine
-		go processRecord(record)
+	for record := range p.ExtractedChan {
+		p.ProcessingWG.Add(1)
+		p.workerSem <- struct{}{} // Block here when every worker slot already runs a processing goroutine
+		go p.processRecord(ctx, record)
 	}
+
+	return sourceErr
 }
 
-// processRecord processes a record (sending to the ResultChan)
-func processRecord(record map[string]interface{}) {
-	defer ProcessingWG.Done()
-	defer func() { <-workerSem }() // Release semaphore
+// processRecord processes a record (sending to the ResultChan unless ctx is cancelled)
+func (p *Pipeline) processRecord(ctx context.Context, record map[string]interface{}) {
+	defer p.ProcessingWG.Done()
+	defer func() { <-p.workerSem }() // Release semaphore
 
-	TransformMetrics.mu.Lock()
-	TransformMetrics.Processed += 1
-	TransformMetrics.mu.Unlock()
+	p.Metrics.mu.Lock()
+	p.Metrics.Processed += 1
+	p.Metrics.mu.Unlock()
 
 	// Create Record and apply transformations
 	var rec models.Record
@@ -54,41 +107,59 @@ func processRecord(record map[string]interface{}) {
 			"error":  err,
 		}).Warn("record creation failed; not emitting")
 
-		TransformMetrics.mu.Lock()
-		TransformMetrics.TransformFailed += 1
-		TransformMetrics.mu.Unlock()
+		p.Metrics.mu.Lock()
+		p.Metrics.TransformFailed += 1
+		p.Metrics.mu.Unlock()
 		return
 	}
 
-	if err := rec.Update(); err != nil {
+	if err := rec.Update(&p.config.Records); err != nil {
 		recordWithError, _ := json.Marshal(record)
 		log.WithFields(log.Fields{
 			"record": json.RawMessage(recordWithError), // logs as nested JSON, no escaping
 			"error":  err,
 		}).Warn("record transformation failed; not emitting")
 
-		TransformMetrics.mu.Lock()
-		TransformMetrics.TransformFailed += 1
-		TransformMetrics.mu.Unlock()
+		p.Metrics.mu.Lock()
+		p.Metrics.TransformFailed += 1
+		p.Metrics.mu.Unlock()
 		return
 	}
 
 	// Evaluate bookmark filtering against the previous state before updating it
-	// so new records still emit on the first run.
-	passesBookmark := rec.PassesBookmark()
+	// so new records still emit on the first run. Full refresh and discovery skip the check.
+	passesBookmark := p.fullRefresh || p.discover || rec.PassesBookmark(p.state.PreviousBookmark, &p.config.Records)
 
 	// Check if record passes bookmark filter
 	if !passesBookmark {
 		// Unchanged records still refresh bookmark state so last_seen remains current.
-		if !models.DISCOVER_MODE {
-			models.State.QueueBookmarkUpdate(rec.ToMap(), false)
+		if !p.discover {
+			p.state.QueueBookmarkUpdate(rec.ToMap(), false)
 		}
 
-		TransformMetrics.mu.Lock()
-		TransformMetrics.FilteredBookmark += 1
-		TransformMetrics.mu.Unlock()
+		p.Metrics.mu.Lock()
+		p.Metrics.FilteredBookmark += 1
+		p.Metrics.mu.Unlock()
 		return
 	}
 
-	ResultChan <- rec
+	// Validation runs here so it scales with the worker pool rather than the single emitting goroutine
+	if !p.discover {
+		if valid, err := p.catalog.ValidateRecordAgainstCatalog(rec.ToMap()); !valid {
+			log.WithFields(log.Fields{
+				"_sdc_natural_key": rec["_sdc_natural_key"],
+				"error":            err,
+			}).Warn("record failed schema validation; not emitting")
+
+			p.Metrics.mu.Lock()
+			p.Metrics.SchemaValidationFailed += 1
+			p.Metrics.mu.Unlock()
+			return
+		}
+	}
+
+	select {
+	case p.ResultChan <- rec:
+	case <-ctx.Done():
+	}
 }
@@ -0,0 +1,141 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+// sliceSource streams the given records, stopping early if ctx is cancelled
+func sliceSource(source []map[string]interface{}, err error) SourceFunc {
+	return func(ctx context.Context, config *models.StreamConfig, records chan<- map[string]interface{}) error {
+		for _, record := range source {
+			select {
+			case records <- record:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return err
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	config := &models.StreamConfig{StreamName: "users", SourceType: "jsonl", MaxConcurrency: 2}
+	config.Records.UniqueKeyPath = []string{"id"}
+
+	catalog := &models.StreamCatalog{Stream: "users", Schema: models.Schema{
+		"type":       "object",
+		"properties": map[string]interface{}{"id": map[string]interface{}{"type": "number"}, "name": map[string]interface{}{"type": "string"}},
+	}}
+	if err := catalog.Compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The bookmark of the last run holds user 2 unchanged
+	var unchanged models.Record
+	unchanged.Create(map[string]interface{}{"id": float64(2), "name": "b"})
+	if err := unchanged.Update(&config.Records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	previous := models.Bookmark{Latest: map[string]models.BookmarkEntry{
+		"2": {SurrogateKey: unchanged["_sdc_surrogate_key"].(string), HashVersion: models.SurrogateKeyVersion},
+	}}
+
+	// Records are transformed in place, so every run reads new ones
+	source := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"id": float64(1), "name": "a"},
+			{"id": float64(2), "name": "b"},
+			{"name": "no id"},
+			{"id": float64(3), "name": 3},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		fullRefresh       bool
+		discover          bool
+		sourceErr         error
+		emitted           string
+		filtered, invalid uint64
+		transformFailed   uint64
+	}{
+		{name: "incremental", emitted: "[1]", filtered: 1, invalid: 1, transformFailed: 1},
+		{name: "full refresh", fullRefresh: true, emitted: "[1 2]", invalid: 1, transformFailed: 1},
+		{name: "discovery", discover: true, emitted: "[1 2 3]", transformFailed: 1},
+		{name: "source error", sourceErr: errors.New("connection reset"), emitted: "[1]", filtered: 1, invalid: 1, transformFailed: 1},
+	}
+
+	for _, test := range tests {
+		state := &models.StreamState{Stream: "users", PreviousBookmark: previous}
+		state.StartBookmarkUpdates()
+		pipeline := NewPipeline(config, state, catalog, test.fullRefresh, test.discover)
+
+		var err error
+		go func() {
+			err = pipeline.ExtractRecords(context.Background(), sliceSource(source(), test.sourceErr))
+			pipeline.ProcessingWG.Wait()
+			close(pipeline.ResultChan)
+		}()
+
+		var ids []int
+		for record := range pipeline.ResultChan {
+			ids = append(ids, int(record["id"].(float64)))
+		}
+		sort.Ints(ids)
+		state.StopBookmarkUpdates()
+
+		if err != test.sourceErr {
+			t.Errorf("%s: got source error %v, want %v", test.name, err, test.sourceErr)
+		}
+		if got := fmt.Sprint(ids); got != test.emitted {
+			t.Errorf("%s: emitted %s, want %s", test.name, got, test.emitted)
+		}
+		metrics := pipeline.Metrics
+		if metrics.Processed != 4 || metrics.FilteredBookmark != test.filtered || metrics.SchemaValidationFailed != test.invalid || metrics.TransformFailed != test.transformFailed {
+			t.Errorf("%s: got metrics %+v", test.name, metrics)
+		}
+		// Filtered records still refresh their bookmark
+		if _, seen := state.Bookmark.Latest["2"]; seen != (test.filtered > 0) {
+			t.Errorf("%s: bookmark of the filtered record updated: %v", test.name, seen)
+		}
+	}
+}
+
+func TestPipelineCancelled(t *testing.T) {
+	config := &models.StreamConfig{StreamName: "users", SourceType: "jsonl", MaxConcurrency: 2, ResultBuffer: 1}
+	config.Records.UniqueKeyPath = []string{"id"}
+
+	source := make([]map[string]interface{}, 100)
+	for i := range source {
+		source[i] = map[string]interface{}{"id": float64(i)}
+	}
+
+	pipeline := NewPipeline(config, &models.StreamState{}, &models.StreamCatalog{}, true, true)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Nothing reads the results, as when a run returns early, so only cancellation lets the pipeline finish
+	done := make(chan error)
+	go func() {
+		err := pipeline.ExtractRecords(ctx, sliceSource(source, nil))
+		pipeline.ProcessingWG.Wait()
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("source and workers still running after cancellation")
+	}
+}
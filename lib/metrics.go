@@ -7,14 +7,13 @@ import (
 
 // TransformationMetrics tracks record transformation statistics.
 type TransformationMetrics struct {
-	Processed        uint64 `json:"processed"`
-	TransformFailed  uint64 `json:"transform_failed"`
-	FilteredBookmark uint64 `json:"filtered_bookmark"`
-	mu               sync.Mutex
+	Processed              uint64 `json:"processed"`
+	TransformFailed        uint64 `json:"transform_failed"`
+	FilteredBookmark       uint64 `json:"filtered_bookmark"`
+	SchemaValidationFailed uint64 `json:"schema_validation_failed"`
+	mu                     sync.Mutex
 }
 
-var TransformMetrics = &TransformationMetrics{}
-
 type NotEmittedMetric struct {
 	Total                  uint64 `json:"total"`
 	FilteredBookmark       uint64 `json:"filtered_bookmark"`
@@ -27,7 +26,10 @@ type ExecutionMetric struct {
 	ExecutionEnd      time.Time     `json:"execution_end,omitempty"`
 	ExecutionDuration time.Duration `json:"execution_duration,omitempty"`
 
+	Concurrency int `json:"concurrency"`
+
 	Emitted uint64 `json:"emitted"`
+	Deleted uint64 `json:"deleted"`
 
 	Processed          uint64           `json:"processed"`
 	ProcessedPerSecond float64          `json:"processed_per_second"`
@@ -38,18 +40,19 @@ func NewExecutionMetric() ExecutionMetric {
 	return ExecutionMetric{ExecutionStart: time.Now().UTC()}
 }
 
-func (execution *ExecutionMetric) addTransformMetrics() {
-	execution.Processed = TransformMetrics.Processed
-	execution.NotEmitted.FilteredBookmark = TransformMetrics.FilteredBookmark
-	execution.NotEmitted.TransformFailed = TransformMetrics.TransformFailed
+func (execution *ExecutionMetric) addTransformMetrics(transform *TransformationMetrics) {
+	execution.Processed = transform.Processed
+	execution.NotEmitted.FilteredBookmark = transform.FilteredBookmark
+	execution.NotEmitted.TransformFailed = transform.TransformFailed
+	execution.NotEmitted.SchemaValidationFailed = transform.SchemaValidationFailed
 	execution.NotEmitted.Total = execution.NotEmitted.FilteredBookmark + execution.NotEmitted.SchemaValidationFailed + execution.NotEmitted.TransformFailed
 }
 
-func (execution *ExecutionMetric) Complete() {
+func (execution *ExecutionMetric) Complete(transform *TransformationMetrics) {
 	execution.ExecutionEnd = time.Now().UTC()
 	execution.ExecutionDuration = execution.ExecutionEnd.Sub(execution.ExecutionStart)
 
-	execution.addTransformMetrics()
+	execution.addTransformMetrics(transform)
 
 	if execution.ExecutionDuration.Seconds() > 0 {
 		execution.ProcessedPerSecond = float64(execution.Processed) / execution.ExecutionDuration.Seconds()
@@ -1,18 +1,40 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/5amCurfew/xtkt/cmd"
 	"github.com/5amCurfew/xtkt/models"
+	"github.com/5amCurfew/xtkt/xtkt"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var version = "0.8.5"
+
+// Process exit codes, so orchestrators can distinguish a broken config from a failing source
+const (
+	exitFailure       = 1
+	exitConfigError   = 2
+	exitSourceFailure = 3
+)
+
 var discover bool = false
 var refresh bool = false
+var streams []string
+var concurrency int
+var parallelStreams int
+var configPath string
+var catalogPath string
+var propertiesPath string
+var statePath string
+var confirmedState bool
+var commitState bool
 
 func main() {
 	Execute()
@@ -26,9 +48,34 @@ func Execute() {
 
 	rootCmd.Flags().BoolVarP(&discover, "discover", "d", false, "run the tap in discovery mode, creating the catalog")
 	rootCmd.Flags().BoolVarP(&refresh, "refresh", "r", false, "extract all records (full refresh) rather than only new or modified records (incremental, default)")
+	rootCmd.Flags().IntVarP(&concurrency, "concurrency", "c", 0, "number of records transformed concurrently per stream, overriding max_concurrency (default one per CPU)")
+	rootCmd.Flags().IntVar(&parallelStreams, "parallel-streams", cmd.DefaultParallelStreams, "number of streams of a multi-stream config run at once")
+	rootCmd.Flags().StringSliceVarP(&streams, "stream", "s", nil, "run only the named stream(s) from a multi-stream config (repeatable or comma-separated)")
+	rootCmd.Flags().StringVar(&configPath, "config", "", "path to config JSON (Singer-style alternative to PATH_TO_CONFIG_JSON); with --discover the catalog is written to stdout")
+	rootCmd.Flags().StringVar(&catalogPath, "catalog", "", "path to a Singer catalog JSON ({\"streams\":[...]}) used instead of <stream_name>_catalog.json")
+	rootCmd.Flags().StringVar(&propertiesPath, "properties", "", "deprecated Singer alias for --catalog")
+	rootCmd.Flags().StringVar(&statePath, "state", "", "path to a Singer state JSON used instead of <stream_name>_state.json")
+	rootCmd.Flags().BoolVar(&confirmedState, "confirmed-state", false, "do not write <stream_name>_state.json; state is only advanced by --commit-state from the state the target confirms")
+	rootCmd.Flags().BoolVar(&commitState, "commit-state", false, "read the state echoed by a target from stdin and write it to <stream_name>_state.json")
+	rootCmd.MarkFlagsMutuallyExclusive("catalog", "properties")
+	rootCmd.MarkFlagsMutuallyExclusive("commit-state", "confirmed-state")
+	rootCmd.MarkFlagsMutuallyExclusive("commit-state", "discover")
 
 	if err := rootCmd.Execute(); err != nil {
-		log.WithField("error", err).Fatal("command execution failed")
+		log.WithField("error", err).Error("command execution failed")
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode maps a command error onto a process exit code; config errors take precedence over source failures
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, xtkt.ErrConfig):
+		return exitConfigError
+	case errors.Is(err, xtkt.ErrSource):
+		return exitSourceFailure
+	default:
+		return exitFailure
 	}
 }
 
@@ -36,31 +83,82 @@ var rootCmd = &cobra.Command{
 	Use:     "xtkt [PATH_TO_CONFIG_JSON]",
 	Version: version,
 	Short:   "xtkt - data extraction CLI",
-	Long:    `xtkt is a command line interface to extract data from RESTful APIs, CSVs, and JSONL files to pipe to any target that meets the Singer.io specification.`,
+	Long:    `xtkt is a command line interface to extract data from RESTful and GraphQL APIs, databases, HTML tables, CSVs, and JSONL files to pipe to any target that meets the Singer.io specification.`,
 	Args:    cobra.MaximumNArgs(1),
 	RunE: func(command *cobra.Command, args []string) error {
 		// Default to config.json if no path is provided
 		cfgPath := "config.json"
-		if len(args) > 0 {
+		switch {
+		case len(args) > 0 && configPath != "":
+			return fmt.Errorf("%w: config path given both as an argument and --config", xtkt.ErrConfig)
+		case len(args) > 0:
 			cfgPath = args[0]
-		} else {
+		case configPath != "":
+			cfgPath = configPath
+		default:
 			log.WithField("config_path", cfgPath).Info("no config path provided; using default")
 		}
 
-		if err := models.Config.Create(cfgPath); err != nil {
-			return fmt.Errorf("error parsing config JSON: %w", err)
+		var config models.StreamsConfig
+		if err := config.Create(cfgPath); err != nil {
+			return fmt.Errorf("%w: error parsing config JSON: %w", xtkt.ErrConfig, err)
 		}
 
-		models.STREAM_NAME = models.Config.StreamName
+		selected, err := config.Select(streams)
+		if err != nil {
+			return fmt.Errorf("%w: error selecting streams: %w", xtkt.ErrConfig, err)
+		}
 
-		var err error
-		if discover {
-			err = cmd.Discover(refresh)
-		} else {
-			err = cmd.Extract(refresh)
+		if commitState {
+			if err := cmd.CommitState(os.Stdin, selected); err != nil {
+				return fmt.Errorf("command run failed: %w", err)
+			}
+			return nil
 		}
 
-		if err != nil {
+		if command.Flags().Changed("concurrency") {
+			if concurrency < 1 || concurrency > models.MaxConcurrencyLimit {
+				return fmt.Errorf("%w: --concurrency must be between 1 and %d, got %d", xtkt.ErrConfig, models.MaxConcurrencyLimit, concurrency)
+			}
+			for i := range selected {
+				selected[i].MaxConcurrency = concurrency
+			}
+		}
+
+		if parallelStreams < 1 || parallelStreams > models.MaxConcurrencyLimit {
+			return fmt.Errorf("%w: --parallel-streams must be between 1 and %d, got %d", xtkt.ErrConfig, models.MaxConcurrencyLimit, parallelStreams)
+		}
+
+		options := cmd.Options{
+			Discover:        discover,
+			Refresh:         refresh,
+			ParallelStreams: parallelStreams,
+			// Singer runners pass --config and expect discovery to print the catalog to stdout
+			WriteCatalog:   configPath != "",
+			ConfirmedState: confirmedState,
+		}
+
+		if catalogPath == "" {
+			catalogPath = propertiesPath
+		}
+		if catalogPath != "" {
+			options.Catalog = &models.TapCatalog{}
+			if err := options.Catalog.Create(catalogPath); err != nil {
+				return fmt.Errorf("%w: error parsing catalog JSON: %w", xtkt.ErrConfig, err)
+			}
+		}
+
+		if statePath != "" {
+			options.State = &models.TapState{}
+			if err := options.State.Create(statePath); err != nil {
+				return fmt.Errorf("%w: error parsing state JSON: %w", xtkt.ErrConfig, err)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := cmd.RunStreams(ctx, selected, options, os.Stdout); err != nil {
 			return fmt.Errorf("command run failed: %w", err)
 		}
 
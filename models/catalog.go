@@ -3,7 +3,9 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 
 	util "github.com/5amCurfew/xtkt/util"
 	"github.com/xeipuuv/gojsonschema"
@@ -20,19 +22,39 @@ type StreamCatalog struct {
 	Schema             Schema   `json:"schema"`
 	SchemaDiscoveredAt string   `json:"schema_discovered_at,omitempty"`
 	Stream             string   `json:"stream"`
-}
 
-var DerivedCatalog StreamCatalog
+	compiled *gojsonschema.Schema // set by Compile, shared by every validation of a run
+}
 
 // Create creates a catalog JSON file for the stream
+// Expects a string parameter containing the stream name, optionally followed by a
+// *StreamCatalog supplied by the caller (e.g. --catalog) which is used instead of the catalog file
 func (c *StreamCatalog) Create(source ...interface{}) error {
+	if len(source) > 0 {
+		streamName, ok := source[0].(string)
+		if !ok {
+			return fmt.Errorf("stream name must be string, got %T", source[0])
+		}
+		c.Stream = streamName
+	}
+
+	if len(source) > 1 {
+		input, ok := source[1].(*StreamCatalog)
+		if !ok {
+			return fmt.Errorf("input catalog must be *StreamCatalog, got %T", source[1])
+		}
+		c.KeyProperties = input.KeyProperties
+		c.Schema = input.Schema
+		c.SchemaDiscoveredAt = input.SchemaDiscoveredAt
+		return nil
+	}
+
 	// Check if file already exists
-	if _, err := os.Stat(fmt.Sprintf("%s_catalog.json", STREAM_NAME)); err == nil {
+	if _, err := os.Stat(fmt.Sprintf("%s_catalog.json", c.Stream)); err == nil && c.Stream != "" {
 		// File exists, read it instead of creating new
 		return c.Read()
 	}
 
-	c.Stream = STREAM_NAME
 	if c.Stream == "" {
 		return fmt.Errorf("error creating catalog file: stream name is required")
 	}
@@ -50,7 +72,7 @@ func (c *StreamCatalog) Create(source ...interface{}) error {
 
 // Read the Catalog JSON file
 func (c *StreamCatalog) Read() error {
-	catalogFile, err := os.ReadFile(fmt.Sprintf("%s_catalog.json", STREAM_NAME))
+	catalogFile, err := os.ReadFile(fmt.Sprintf("%s_catalog.json", c.Stream))
 	if err != nil {
 		return fmt.Errorf("error reading catalog file: %w", err)
 	}
@@ -72,12 +94,28 @@ func (c *StreamCatalog) Update() error {
 	return nil
 }
 
-// ValidateRecordAgainstCatalog validates record against Catalog
+// Compile compiles the catalog schema for validation, failing if the schema is not valid JSON Schema.
+// It must be called again whenever the schema changes.
+func (c *StreamCatalog) Compile() error {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(c.Schema))
+	if err != nil {
+		return fmt.Errorf("invalid schema in catalog for stream %s: %w", c.Stream, err)
+	}
+	c.compiled = compiled
+	return nil
+}
+
+// ValidateRecordAgainstCatalog validates record against Catalog using the compiled schema.
+// Safe for concurrent use once Compile has been called.
 func (c *StreamCatalog) ValidateRecordAgainstCatalog(record map[string]interface{}) (bool, error) {
-	schemaLoader := gojsonschema.NewGoLoader(c.Schema)
-	recordLoader := gojsonschema.NewGoLoader(record)
+	if c.compiled == nil {
+		return false, fmt.Errorf("catalog schema for stream %s not compiled", c.Stream)
+	}
 
-	result, _ := gojsonschema.Validate(schemaLoader, recordLoader)
+	result, err := c.compiled.Validate(gojsonschema.NewGoLoader(record))
+	if err != nil {
+		return false, fmt.Errorf("error validating record: %w", err)
+	}
 
 	if result.Valid() {
 		return true, nil
@@ -86,9 +124,49 @@ func (c *StreamCatalog) ValidateRecordAgainstCatalog(record map[string]interface
 	return false, fmt.Errorf("%s", result.Errors())
 }
 
+// NaturalKeyFromBookmark converts a bookmark key back to the _sdc_natural_key type declared in the schema
+func (c *StreamCatalog) NaturalKeyFromBookmark(key string) interface{} {
+	prop, _ := c.Schema.Properties()["_sdc_natural_key"].(map[string]interface{})
+	switch prop["type"] {
+	case "number":
+		if number, err := strconv.ParseFloat(key, 64); err == nil {
+			return number
+		}
+	case "array":
+		// Composite keys are stored as their JSON encoding
+		var components []interface{}
+		if err := json.Unmarshal([]byte(key), &components); err == nil {
+			return components
+		}
+	}
+	return key
+}
+
+// WithDeletedAt returns a copy of the catalog whose schema declares _sdc_deleted_at.
+// Tombstones carry the field, but discovery never observes it in source records.
+func (c StreamCatalog) WithDeletedAt() StreamCatalog {
+	properties := make(map[string]interface{}, len(c.Schema.Properties())+1)
+	for key, value := range c.Schema.Properties() {
+		properties[key] = value
+	}
+	properties["_sdc_deleted_at"] = map[string]interface{}{
+		"type":   []string{"string", "null"},
+		"format": "date-time",
+	}
+
+	schema := Schema{}
+	for key, value := range c.Schema {
+		schema[key] = value
+	}
+	schema["properties"] = properties
+	c.Schema = schema
+
+	return c
+}
+
 // Message generates a schema message from the derived catalog
-func (c *StreamCatalog) Message() error {
+func (c *StreamCatalog) Message(w io.Writer) error {
 	var schema Schema
 	schema.Create(c.Schema)
-	return schema.Message()
+	return schema.Message(w, c.Stream)
 }
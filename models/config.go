@@ -3,11 +3,19 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	util "github.com/5amCurfew/xtkt/util"
 )
 
-// Compile-time verification that StreamConfig implements Model interface
+// Compile-time verification that StreamConfig and StreamsConfig implement Model interface
 var _ Model = (*StreamConfig)(nil)
+var _ Model = (*StreamsConfig)(nil)
 
 // StreamConfig represents the configuration for a data stream.
 // It defines the source type, connection details, authentication, and record processing rules.
@@ -16,15 +24,15 @@ type StreamConfig struct {
 	SourceType     string        `json:"source_type,omitempty"`
 	URL            string        `json:"url,omitempty"`
 	MaxConcurrency int           `json:"max_concurrency,omitempty"`
+	ResultBuffer   int           `json:"result_buffer,omitempty"`
+	StateInterval  int           `json:"state_interval,omitempty"`
 	Records        RecordsConfig `json:"records,omitempty"`
 	Rest           RestConfig    `json:"rest,omitempty"`
+	GraphQL        GraphQLConfig `json:"graphql,omitempty"`
+	DB             DBConfig      `json:"db,omitempty"`
+	HTML           HTMLConfig    `json:"html,omitempty"`
 }
 
-var Config StreamConfig
-var STREAM_NAME string
-var FULL_REFRESH bool
-var DISCOVER_MODE bool
-
 // Create loads the StreamConfig from a JSON file
 // Expects a single string parameter containing the file path
 func (c *StreamConfig) Create(source ...interface{}) error {
@@ -47,6 +55,146 @@ func (c *StreamConfig) Create(source ...interface{}) error {
 	return nil
 }
 
+// MaxConcurrencyLimit and ResultBufferLimit bound the worker pool and result buffer sizes accepted from config
+const MaxConcurrencyLimit = 1024
+const ResultBufferLimit = 100000
+
+// SourceTypes lists the supported values of source_type
+var SourceTypes = []string{"csv", "db", "graphql", "html", "jsonl", "rest"}
+
+// PaginationStrategies lists the supported values of rest.response.pagination_strategy
+var PaginationStrategies = []string{"cursor", "link_header", "next", "offset", "query"}
+
+// Validate checks the configuration for values that cannot be run
+func (c *StreamConfig) Validate() error {
+	supported := false
+	for _, sourceType := range SourceTypes {
+		supported = supported || c.SourceType == sourceType
+	}
+	if !supported {
+		return fmt.Errorf("unsupported source_type %q; expected one of %v", c.SourceType, SourceTypes)
+	}
+
+	if c.MaxConcurrency < 0 || c.MaxConcurrency > MaxConcurrencyLimit {
+		return fmt.Errorf("max_concurrency must be between 1 and %d (or omitted for one worker per CPU), got %d", MaxConcurrencyLimit, c.MaxConcurrency)
+	}
+
+	if c.ResultBuffer < 0 || c.ResultBuffer > ResultBufferLimit {
+		return fmt.Errorf("result_buffer must be between 1 and %d (or omitted for 100), got %d", ResultBufferLimit, c.ResultBuffer)
+	}
+
+	if len(c.Records.UniqueKeyPath) > 0 && len(c.Records.UniqueKeyPaths) > 0 {
+		return fmt.Errorf("records.unique_key_path and records.unique_key_paths are mutually exclusive")
+	}
+	for _, path := range c.Records.UniqueKeyPaths {
+		if len(path) == 0 {
+			return fmt.Errorf("records.unique_key_paths must not contain an empty path")
+		}
+	}
+	// Keys are a single value of each record, so their paths may use array indices but not wildcards
+	for _, path := range append([][]string{c.Records.UniqueKeyPath, c.Records.ReplicationKeyPath}, c.Records.UniqueKeyPaths...) {
+		if util.PathMatchesMany(path) {
+			return fmt.Errorf("records key path %v must not use wildcards (\"*\" or \"**\")", path)
+		}
+	}
+
+	if c.Records.DeletionDetection.GracePeriod < 0 {
+		return fmt.Errorf("records.deletion_detection.grace_period must not be negative, got %d", c.Records.DeletionDetection.GracePeriod)
+	}
+
+	if err := c.Records.ChangeDetection.validate(); err != nil {
+		return err
+	}
+
+	for i := range c.Records.SensitiveFields {
+		if err := c.Records.SensitiveFields[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.SourceType == "rest" {
+		if c.Rest.Response.Pagination {
+			if err := c.Rest.Response.validatePagination(); err != nil {
+				return err
+			}
+		}
+		if c.Rest.Auth.Required && c.Rest.Auth.Strategy == "oauth" {
+			if err := c.Rest.Auth.OAuth.validate(); err != nil {
+				return err
+			}
+		}
+		if err := c.Rest.Request.validate(); err != nil {
+			return err
+		}
+		if err := c.Rest.Replication.validate(); err != nil {
+			return err
+		}
+		// A GET request has no body, so values sent in the body need the default POST or another method
+		if strings.ToUpper(c.Rest.Request.Method) == "GET" {
+			switch {
+			case c.Rest.Request.Body != nil:
+				return fmt.Errorf("rest.request.body cannot be sent with rest.request.method GET; omit the method (POST by default with a body) or use POST, PUT or PATCH")
+			case c.Rest.Response.PaginatesInBody():
+				return fmt.Errorf("pagination values with \"location\": \"body\" cannot be sent with rest.request.method GET; use \"location\": \"query\", or omit the method (POST by default with a body)")
+			case c.Rest.Replication.Parameter != "" && c.Rest.Replication.Location == "body":
+				return fmt.Errorf("rest.replication with \"location\": \"body\" cannot be sent with rest.request.method GET; use \"location\": \"query\", or omit the method (POST by default with a body)")
+			}
+		}
+		if err := c.Rest.Parent.validate(c.URL); err != nil {
+			return err
+		}
+		if c.Rest.Replication.Parameter != "" && len(c.Records.ReplicationKeyPath) == 0 {
+			return fmt.Errorf("records.replication_key_path is required for rest.replication")
+		}
+		if c.Rest.Replication.Parameter != "" && c.Records.DeletionDetection.Enabled {
+			return fmt.Errorf("records.deletion_detection cannot be enabled with rest.replication, as incremental runs only see changed records")
+		}
+		if err := c.Rest.Retry.validate(); err != nil {
+			return err
+		}
+		if err := c.Rest.RateLimit.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.SourceType == "graphql" {
+		if err := c.GraphQL.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.SourceType == "html" {
+		if err := c.HTML.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.SourceType == "db" {
+		if err := c.DB.validate(); err != nil {
+			return err
+		}
+	}
+
+	// The replication key is read from transformed records, so no drop, hash or mask path may reach it
+	if replicationKey := c.Records.ReplicationKeyPath; len(replicationKey) > 0 {
+		paths := append(append([][]string{}, c.Records.DropFieldPaths...), c.Records.SensitiveFieldPaths...)
+		for _, field := range c.Records.SensitiveFields {
+			paths = append(paths, field.Path)
+		}
+		for _, path := range paths {
+			if util.PathReaches(path, replicationKey) {
+				return fmt.Errorf("records.replication_key_path %v must not be dropped, hashed or masked (by path %v)", replicationKey, path)
+			}
+		}
+	}
+
+	if c.StateInterval < 0 {
+		return fmt.Errorf("state_interval must be a positive number of records (or omitted for a STATE message at the end of the run only), got %d", c.StateInterval)
+	}
+
+	return nil
+}
+
 // Read reads the configuration (JSON file is loaded via Create, so this is a no-op)
 func (c *StreamConfig) Read() error {
 	// Config is loaded via Create method
@@ -60,15 +208,243 @@ func (c *StreamConfig) Update() error {
 }
 
 // Message generates a configuration message (no-op for config)
-func (c *StreamConfig) Message() error {
+func (c *StreamConfig) Message(w io.Writer) error {
 	// Config doesn't generate messages in the current pipeline
 	return nil
 }
 
+// StreamsConfig represents a config file declaring one or more streams.
+// A file without a "streams" array is treated as a single StreamConfig.
+type StreamsConfig struct {
+	Streams []StreamConfig `json:"streams,omitempty"`
+}
+
+// Create loads the StreamsConfig from a JSON file
+// Expects a single string parameter containing the file path
+func (c *StreamsConfig) Create(source ...interface{}) error {
+	if len(source) == 0 {
+		return fmt.Errorf("config file path required")
+	}
+	filePath, ok := source[0].(string)
+	if !ok {
+		return fmt.Errorf("config file path must be string, got %T", source[0])
+	}
+	configData, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var shape map[string]json.RawMessage
+	if err := json.Unmarshal(configData, &shape); err != nil {
+		return fmt.Errorf("error unmarshaling config json: %w", err)
+	}
+
+	if _, ok := shape["streams"]; ok {
+		if err := json.Unmarshal(configData, c); err != nil {
+			return fmt.Errorf("error unmarshaling config json: %w", err)
+		}
+	} else {
+		var single StreamConfig
+		if err := json.Unmarshal(configData, &single); err != nil {
+			return fmt.Errorf("error unmarshaling config json: %w", err)
+		}
+		c.Streams = []StreamConfig{single}
+	}
+
+	if len(c.Streams) == 0 {
+		return fmt.Errorf("config declares no streams")
+	}
+
+	seen := map[string]bool{}
+	for _, stream := range c.Streams {
+		if stream.StreamName == "" {
+			return fmt.Errorf("stream_name is required for every stream")
+		}
+		if seen[stream.StreamName] {
+			return fmt.Errorf("duplicate stream_name %q", stream.StreamName)
+		}
+		seen[stream.StreamName] = true
+	}
+
+	return c.resolveParents()
+}
+
+// resolveParents points the rest.parent of every child stream at its parent stream's config
+func (c *StreamsConfig) resolveParents() error {
+	byName := map[string]*StreamConfig{}
+	for i := range c.Streams {
+		byName[c.Streams[i].StreamName] = &c.Streams[i]
+	}
+
+	for i := range c.Streams {
+		parent := &c.Streams[i].Rest.Parent
+		if parent.Stream == "" {
+			continue
+		}
+		parent.Config = byName[parent.Stream]
+		if parent.Config == nil {
+			return fmt.Errorf("rest.parent.stream %q of stream %q not found in config", parent.Stream, c.Streams[i].StreamName)
+		}
+	}
+
+	// A cycle of parents would request its streams forever
+	for i := range c.Streams {
+		ancestor := c.Streams[i].Rest.Parent.Config
+		for depth := 0; ancestor != nil; depth++ {
+			if depth == len(c.Streams) {
+				return fmt.Errorf("rest.parent of stream %q forms a cycle", c.Streams[i].StreamName)
+			}
+			ancestor = ancestor.Rest.Parent.Config
+		}
+	}
+
+	return nil
+}
+
+// Read reads the configuration (JSON file is loaded via Create, so this is a no-op)
+func (c *StreamsConfig) Read() error {
+	return nil
+}
+
+// Update updates the configuration (no-op for config)
+func (c *StreamsConfig) Update() error {
+	return nil
+}
+
+// Message generates a configuration message (no-op for config)
+func (c *StreamsConfig) Message(w io.Writer) error {
+	return nil
+}
+
+// Select returns the streams with the given names, in config order; all streams when names is empty
+func (c *StreamsConfig) Select(names []string) ([]StreamConfig, error) {
+	if len(names) == 0 {
+		return c.Streams, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var selected []StreamConfig
+	for _, stream := range c.Streams {
+		if wanted[stream.StreamName] {
+			selected = append(selected, stream)
+			delete(wanted, stream.StreamName)
+		}
+	}
+
+	for name := range wanted {
+		return nil, fmt.Errorf("stream %q not found in config", name)
+	}
+
+	return selected, nil
+}
+
 type RecordsConfig struct {
-	UniqueKeyPath       []string   `json:"unique_key_path,omitempty"`
-	DropFieldPaths      [][]string `json:"drop_field_paths,omitempty"`
-	SensitiveFieldPaths [][]string `json:"sensitive_field_paths,omitempty"`
+	UniqueKeyPath       []string                `json:"unique_key_path,omitempty"`
+	UniqueKeyPaths      [][]string              `json:"unique_key_paths,omitempty"`
+	ReplicationKeyPath  []string                `json:"replication_key_path,omitempty"`
+	DropFieldPaths      [][]string              `json:"drop_field_paths,omitempty"`
+	SensitiveFieldPaths [][]string              `json:"sensitive_field_paths,omitempty"`
+	SensitiveFields     []SensitiveFieldConfig  `json:"sensitive_fields,omitempty"`
+	DeletionDetection   DeletionDetectionConfig `json:"deletion_detection,omitempty"`
+	ChangeDetection     ChangeDetectionConfig   `json:"change_detection,omitempty"`
+}
+
+// MaskingStrategies lists the supported values of records.sensitive_fields[].strategy
+var MaskingStrategies = []string{"sha256", "hmac", "tokenise", "mask", "mask_email", "null"}
+
+// DefaultMaskingKeyEnv is the environment variable holding the secret key of hmac and tokenise masking unless key_env names another
+const DefaultMaskingKeyEnv = "XTKT_MASKING_KEY"
+
+// SensitiveFieldConfig masks the field at Path with a Strategy: sha256 (default, as sensitive_field_paths),
+// hmac (HMAC-SHA256 keyed by the secret in KeyEnv), tokenise (keyed FF1 format-preserving encryption of every
+// ASCII letter and digit, as a string), mask (MaskChar for every letter and digit except the last KeepLast), mask_email
+// (MaskChar for the local part, keeping the domain) or null. Every strategy but null is deterministic, so
+// masked values still join across runs and streams sharing a key.
+type SensitiveFieldConfig struct {
+	Path     []string `json:"path,omitempty"`
+	Strategy string   `json:"strategy,omitempty"`
+	KeyEnv   string   `json:"key_env,omitempty"`
+	KeepLast int      `json:"keep_last,omitempty"`
+	MaskChar string   `json:"mask_char,omitempty"`
+}
+
+// StrategyName returns the masking strategy, defaulting to sha256
+func (f SensitiveFieldConfig) StrategyName() string {
+	if f.Strategy == "" {
+		return "sha256"
+	}
+	return f.Strategy
+}
+
+// KeyEnvName returns the environment variable holding the masking key, defaulting to XTKT_MASKING_KEY
+func (f SensitiveFieldConfig) KeyEnvName() string {
+	if f.KeyEnv == "" {
+		return DefaultMaskingKeyEnv
+	}
+	return f.KeyEnv
+}
+
+// validate checks the masking settings, including that the key of keyed strategies is set
+func (f *SensitiveFieldConfig) validate() error {
+	if len(f.Path) == 0 {
+		return fmt.Errorf("records.sensitive_fields path is required")
+	}
+
+	supported := false
+	for _, strategy := range MaskingStrategies {
+		supported = supported || f.StrategyName() == strategy
+	}
+	if !supported {
+		return fmt.Errorf("unsupported records.sensitive_fields strategy %q for %v; expected one of %v", f.Strategy, f.Path, MaskingStrategies)
+	}
+
+	if f.StrategyName() == "hmac" || f.StrategyName() == "tokenise" {
+		if os.Getenv(f.KeyEnvName()) == "" {
+			return fmt.Errorf("environment variable %s holding the %s key for records.sensitive_fields %v is not set", f.KeyEnvName(), f.StrategyName(), f.Path)
+		}
+	}
+	if f.KeepLast < 0 {
+		return fmt.Errorf("records.sensitive_fields keep_last must not be negative, got %d", f.KeepLast)
+	}
+	if f.MaskChar != "" && utf8.RuneCountInString(f.MaskChar) != 1 {
+		return fmt.Errorf("records.sensitive_fields mask_char must be a single character, got %q", f.MaskChar)
+	}
+	return nil
+}
+
+// ChangeDetectionConfig limits the fields hashed into _sdc_surrogate_key, and so the changes that make an
+// incremental run emit a record again, to IncludeFieldPaths or to every field except ExcludeFieldPaths.
+// Excluded fields are still emitted. Changing either list changes every surrogate key once.
+type ChangeDetectionConfig struct {
+	IncludeFieldPaths [][]string `json:"include_field_paths,omitempty"`
+	ExcludeFieldPaths [][]string `json:"exclude_field_paths,omitempty"`
+}
+
+// validate checks the change detection settings
+func (c *ChangeDetectionConfig) validate() error {
+	if len(c.IncludeFieldPaths) > 0 && len(c.ExcludeFieldPaths) > 0 {
+		return fmt.Errorf("records.change_detection.include_field_paths and records.change_detection.exclude_field_paths are mutually exclusive")
+	}
+	for _, path := range append(append([][]string{}, c.IncludeFieldPaths...), c.ExcludeFieldPaths...) {
+		if len(path) == 0 {
+			return fmt.Errorf("records.change_detection field paths must not be empty")
+		}
+		if !util.IsPlainPath(path) {
+			return fmt.Errorf("records.change_detection field path %v must not use wildcards or array indices", path)
+		}
+	}
+	return nil
+}
+
+// DeletionDetectionConfig controls tombstone emission for natural keys no longer seen at source.
+// GracePeriod is the number of consecutive missed runs tolerated before a tombstone is emitted.
+type DeletionDetectionConfig struct {
+	Enabled     bool `json:"enabled,omitempty"`
+	GracePeriod int  `json:"grace_period,omitempty"`
 }
 
 type BasicAuthConfig struct {
@@ -81,11 +457,62 @@ type TokenAuthConfig struct {
 	HeaderValue string `json:"header_value,omitempty"`
 }
 
+// OAuthConfig describes how an OAuth 2.0 access token is obtained from the token endpoint.
+// GrantType is one of refresh_token (default), client_credentials or password.
+// Encoding is the token request body encoding, multipart (default) or form (application/x-www-form-urlencoded).
+// ClientAuth sends the client credentials in the request body (default) or as a basic auth header.
 type OAuthConfig struct {
-	ClientID     string `json:"client_id,omitempty"`
-	ClientSecret string `json:"client_secret,omitempty"`
-	RefreshToken string `json:"refresh_token,omitempty"`
-	TokenURL     string `json:"token_url,omitempty"`
+	GrantType    string   `json:"grant_type,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	Username     string   `json:"username,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	Audience     string   `json:"audience,omitempty"`
+	TokenURL     string   `json:"token_url,omitempty"`
+	Encoding     string   `json:"encoding,omitempty"`
+	ClientAuth   string   `json:"client_auth,omitempty"`
+}
+
+// GrantTypeName returns the OAuth grant type, defaulting to refresh_token
+func (o OAuthConfig) GrantTypeName() string {
+	if o.GrantType == "" {
+		return "refresh_token"
+	}
+	return o.GrantType
+}
+
+// validate checks the OAuth settings required by the grant type
+func (o *OAuthConfig) validate() error {
+	if o.TokenURL == "" {
+		return fmt.Errorf("auth.oauth.token_url is required for oauth authentication")
+	}
+
+	switch o.GrantTypeName() {
+	case "refresh_token":
+		if o.RefreshToken == "" {
+			return fmt.Errorf("auth.oauth.refresh_token is required for the refresh_token grant")
+		}
+	case "password":
+		if o.Username == "" {
+			return fmt.Errorf("auth.oauth.username is required for the password grant")
+		}
+		if o.Password == "" {
+			return fmt.Errorf("auth.oauth.password is required for the password grant")
+		}
+	case "client_credentials":
+	default:
+		return fmt.Errorf("unsupported auth.oauth.grant_type %q; expected one of refresh_token, client_credentials or password", o.GrantType)
+	}
+
+	if o.Encoding != "" && o.Encoding != "multipart" && o.Encoding != "form" {
+		return fmt.Errorf("auth.oauth.encoding must be multipart or form, got %q", o.Encoding)
+	}
+	if o.ClientAuth != "" && o.ClientAuth != "body" && o.ClientAuth != "basic" {
+		return fmt.Errorf("auth.oauth.client_auth must be body or basic, got %q", o.ClientAuth)
+	}
+	return nil
 }
 
 type AuthConfig struct {
@@ -96,21 +523,524 @@ type AuthConfig struct {
 	OAuth    OAuthConfig     `json:"oauth,omitempty"`
 }
 
+// PaginationQueryConfig increments a page number from QueryValue by QueryIncrement for each page.
+// Location is where the page number is sent, the query string (default) or the JSON request body.
 type PaginationQueryConfig struct {
 	QueryParameter string `json:"query_parameter,omitempty"`
 	QueryValue     int    `json:"query_value,omitempty"`
 	QueryIncrement int    `json:"query_increment,omitempty"`
+	Location       string `json:"location,omitempty"`
+}
+
+// PaginationCursorConfig sends an opaque cursor from each page back with the next request.
+// The cursor is read from CursorPath in the response, or from CursorRecordPath in the last record
+// (e.g. Stripe's starting_after), and pagination stops once HasMorePath is false or no cursor is returned.
+type PaginationCursorConfig struct {
+	CursorPath       []string `json:"cursor_path,omitempty"`
+	CursorRecordPath []string `json:"cursor_record_path,omitempty"`
+	Parameter        string   `json:"parameter,omitempty"`
+	Location         string   `json:"location,omitempty"`
+	HasMorePath      []string `json:"has_more_path,omitempty"`
+}
+
+// PaginationOffsetConfig pages through limit records at a time by offset, advancing by the records received,
+// as servers may cap the limit. It stops once the offset reaches the total found at TotalPath or, without
+// one, at a short page. With a total, Parallel > 1 fetches the remaining pages concurrently. Location is
+// where the offset and limit are sent, the query string (default) or the JSON request body.
+type PaginationOffsetConfig struct {
+	OffsetParameter string   `json:"offset_parameter,omitempty"`
+	LimitParameter  string   `json:"limit_parameter,omitempty"`
+	Offset          int      `json:"offset,omitempty"`
+	Limit           int      `json:"limit,omitempty"`
+	TotalPath       []string `json:"total_path,omitempty"`
+	Parallel        int      `json:"parallel,omitempty"`
+	Location        string   `json:"location,omitempty"`
+}
+
+// OffsetParameterName returns the offset query parameter, defaulting to "offset"
+func (o PaginationOffsetConfig) OffsetParameterName() string {
+	if o.OffsetParameter == "" {
+		return "offset"
+	}
+	return o.OffsetParameter
+}
+
+// LimitParameterName returns the limit query parameter, defaulting to "limit"
+func (o PaginationOffsetConfig) LimitParameterName() string {
+	if o.LimitParameter == "" {
+		return "limit"
+	}
+	return o.LimitParameter
 }
 
 type ResponseConfig struct {
-	RecordsPath        []string              `json:"records_path,omitempty"`
-	Pagination         bool                  `json:"pagination,omitempty"`
-	PaginationStrategy string                `json:"pagination_strategy,omitempty"`
-	PaginationNextPath []string              `json:"pagination_next_path,omitempty"`
-	PaginationQuery    PaginationQueryConfig `json:"pagination_query,omitempty"`
+	RecordsPath        []string               `json:"records_path,omitempty"`
+	Pagination         bool                   `json:"pagination,omitempty"`
+	PaginationStrategy string                 `json:"pagination_strategy,omitempty"`
+	PaginationNextPath []string               `json:"pagination_next_path,omitempty"`
+	PaginationQuery    PaginationQueryConfig  `json:"pagination_query,omitempty"`
+	PaginationCursor   PaginationCursorConfig `json:"pagination_cursor,omitempty"`
+	PaginationOffset   PaginationOffsetConfig `json:"pagination_offset,omitempty"`
+}
+
+// PaginatesInBody reports whether the pagination strategy sends its values in the JSON request body
+func (r ResponseConfig) PaginatesInBody() bool {
+	if !r.Pagination {
+		return false
+	}
+	switch r.PaginationStrategy {
+	case "cursor":
+		return r.PaginationCursor.Location == "body"
+	case "offset":
+		return r.PaginationOffset.Location == "body"
+	case "query":
+		return r.PaginationQuery.Location == "body"
+	}
+	return false
+}
+
+// validateLocation checks a pagination location is query (default) or body
+func validateLocation(field string, location string) error {
+	if location != "" && location != "query" && location != "body" {
+		return fmt.Errorf("%s must be query or body, got %q", field, location)
+	}
+	return nil
+}
+
+// validatePagination checks the pagination strategy and the settings it requires
+func (r *ResponseConfig) validatePagination() error {
+	supported := false
+	for _, strategy := range PaginationStrategies {
+		supported = supported || r.PaginationStrategy == strategy
+	}
+	if !supported {
+		return fmt.Errorf("unsupported pagination_strategy %q; expected one of %v", r.PaginationStrategy, PaginationStrategies)
+	}
+
+	if r.PaginationStrategy == "cursor" {
+		cursor := r.PaginationCursor
+		if cursor.Parameter == "" {
+			return fmt.Errorf("pagination_cursor.parameter is required for cursor pagination")
+		}
+		if (len(cursor.CursorPath) == 0) == (len(cursor.CursorRecordPath) == 0) {
+			return fmt.Errorf("exactly one of pagination_cursor.cursor_path and pagination_cursor.cursor_record_path is required for cursor pagination")
+		}
+		if err := validateLocation("pagination_cursor.location", cursor.Location); err != nil {
+			return err
+		}
+	}
+
+	if r.PaginationStrategy == "offset" {
+		offset := r.PaginationOffset
+		if offset.Limit < 1 {
+			return fmt.Errorf("pagination_offset.limit must be at least 1 for offset pagination, got %d", offset.Limit)
+		}
+		if offset.Offset < 0 {
+			return fmt.Errorf("pagination_offset.offset must not be negative, got %d", offset.Offset)
+		}
+		if offset.Parallel < 0 || offset.Parallel > MaxConcurrencyLimit {
+			return fmt.Errorf("pagination_offset.parallel must be between 1 and %d (or omitted for sequential requests), got %d", MaxConcurrencyLimit, offset.Parallel)
+		}
+		if offset.Parallel > 1 && len(offset.TotalPath) == 0 {
+			return fmt.Errorf("pagination_offset.total_path is required for parallel offset pagination")
+		}
+		if err := validateLocation("pagination_offset.location", offset.Location); err != nil {
+			return err
+		}
+	}
+
+	if r.PaginationStrategy == "query" {
+		if r.PaginationQuery.QueryParameter == "" {
+			return fmt.Errorf("pagination_query.query_parameter is required for query pagination")
+		}
+		if err := validateLocation("pagination_query.location", r.PaginationQuery.Location); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RetryConfig retries failed requests with exponential backoff. Transport errors and error responses
+// with a retryable status code (by default 429 and 5xx) are retried, honouring any Retry-After header.
+type RetryConfig struct {
+	MaxAttempts      int     `json:"max_attempts,omitempty"`
+	BaseBackoffMs    int     `json:"base_backoff_ms,omitempty"`
+	MaxBackoffMs     int     `json:"max_backoff_ms,omitempty"`
+	MaxRetryAfterMs  int     `json:"max_retry_after_ms,omitempty"`
+	Jitter           float64 `json:"jitter,omitempty"`
+	RetryStatusCodes []int   `json:"retry_status_codes,omitempty"`
+}
+
+// validate checks the retry settings
+func (r *RetryConfig) validate() error {
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("retry.max_attempts must not be negative, got %d", r.MaxAttempts)
+	}
+	if r.BaseBackoffMs < 0 || r.MaxBackoffMs < 0 || r.MaxRetryAfterMs < 0 {
+		return fmt.Errorf("retry.base_backoff_ms, retry.max_backoff_ms and retry.max_retry_after_ms must not be negative")
+	}
+	if r.Jitter < 0 || r.Jitter > 1 {
+		return fmt.Errorf("retry.jitter must be between 0 and 1, got %v", r.Jitter)
+	}
+	for _, code := range r.RetryStatusCodes {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("retry.retry_status_codes must be error status codes (400 to 599), got %d", code)
+		}
+	}
+	return nil
+}
+
+// RateLimitConfig caps the request rate of a REST stream. With Adaptive, requests are also paused
+// until the reset advertised by the API once the remaining quota in its response headers reaches zero,
+// failing instead when the reset is more than MaxWaitMs away.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	RequestsPerMinute float64 `json:"requests_per_minute,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+	Adaptive          bool    `json:"adaptive,omitempty"`
+	RemainingHeader   string  `json:"remaining_header,omitempty"`
+	ResetHeader       string  `json:"reset_header,omitempty"`
+	MaxWaitMs         int     `json:"max_wait_ms,omitempty"`
+}
+
+// validate checks the rate limit settings
+func (r *RateLimitConfig) validate() error {
+	if r.RequestsPerSecond < 0 || r.RequestsPerMinute < 0 {
+		return fmt.Errorf("rate_limit.requests_per_second and rate_limit.requests_per_minute must not be negative")
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("rate_limit.burst must not be negative, got %d", r.Burst)
+	}
+	if r.MaxWaitMs < 0 {
+		return fmt.Errorf("rate_limit.max_wait_ms must not be negative, got %d", r.MaxWaitMs)
+	}
+	return nil
+}
+
+// RequestMethods lists the supported values of rest.request.method
+var RequestMethods = []string{"GET", "POST", "PUT", "PATCH"}
+
+// RequestConfig describes the HTTP request made for every page of a REST stream.
+// Params are added to the query string of the URL and Body is sent as JSON, with any pagination
+// values sent in the body added to it. Method defaults to GET, or POST when there is a body.
+type RequestConfig struct {
+	Method  string                 `json:"method,omitempty"`
+	Headers map[string]string      `json:"headers,omitempty"`
+	Params  map[string]string      `json:"params,omitempty"`
+	Body    map[string]interface{} `json:"body,omitempty"`
+}
+
+// MethodName returns the HTTP method of the request, defaulting to POST when a body is sent and GET otherwise
+func (r RequestConfig) MethodName(hasBody bool) string {
+	switch {
+	case r.Method != "":
+		return strings.ToUpper(r.Method)
+	case hasBody:
+		return "POST"
+	default:
+		return "GET"
+	}
+}
+
+// validate checks the request settings
+func (r *RequestConfig) validate() error {
+	if r.Method == "" {
+		return nil
+	}
+	for _, method := range RequestMethods {
+		if strings.ToUpper(r.Method) == method {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported request.method %q; expected one of %v", r.Method, RequestMethods)
+}
+
+// ReplicationConfig sends the replication key watermark of the previous run with the request of an
+// incremental run, so the API only returns records changed since (e.g. updated_since). The watermark
+// is moved back by LookbackSeconds, and StartValue is sent when state holds no watermark yet.
+type ReplicationConfig struct {
+	Parameter       string `json:"parameter,omitempty"`
+	Location        string `json:"location,omitempty"`
+	StartValue      string `json:"start_value,omitempty"`
+	LookbackSeconds int    `json:"lookback_seconds,omitempty"`
+}
+
+// validate checks the replication settings
+func (r *ReplicationConfig) validate() error {
+	if r.Parameter == "" {
+		if r.Location != "" || r.StartValue != "" || r.LookbackSeconds != 0 {
+			return fmt.Errorf("rest.replication.parameter is required for rest.replication")
+		}
+		return nil
+	}
+	if err := validateLocation("rest.replication.location", r.Location); err != nil {
+		return err
+	}
+	if r.LookbackSeconds < 0 {
+		return fmt.Errorf("rest.replication.lookback_seconds must not be negative, got %d", r.LookbackSeconds)
+	}
+	return nil
+}
+
+// ApplyReplicationKeyValue adds the replication key watermark, less the lookback window, to the request
+// as rest.replication.parameter. Timestamps are moved back by the lookback in seconds and numbers
+// (e.g. epoch seconds) by the lookback itself. Request params and body are copied before they are changed.
+func (c *StreamConfig) ApplyReplicationKeyValue(watermark interface{}) error {
+	replication := c.Rest.Replication
+	if replication.Parameter == "" {
+		return nil
+	}
+
+	value := watermark
+	if value == nil {
+		if replication.StartValue == "" {
+			return nil
+		}
+		value = replication.StartValue
+	}
+
+	if lookback := replication.LookbackSeconds; lookback > 0 {
+		switch v := value.(type) {
+		case float64:
+			value = v - float64(lookback)
+		case string:
+			timestamp, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return fmt.Errorf("rest.replication.lookback_seconds requires a numeric or RFC 3339 timestamp replication key, got %q", v)
+			}
+			value = timestamp.Add(-time.Duration(lookback) * time.Second).Format(time.RFC3339Nano)
+		default:
+			return fmt.Errorf("rest.replication.lookback_seconds requires a numeric or RFC 3339 timestamp replication key, got %T", v)
+		}
+	}
+
+	if replication.Location == "body" {
+		body := make(map[string]interface{}, len(c.Rest.Request.Body)+1)
+		for key, v := range c.Rest.Request.Body {
+			body[key] = v
+		}
+		body[replication.Parameter] = value
+		c.Rest.Request.Body = body
+		return nil
+	}
+
+	params := make(map[string]string, len(c.Rest.Request.Params)+1)
+	for key, v := range c.Rest.Request.Params {
+		params[key] = v
+	}
+	params[replication.Parameter] = util.ToKeyString(value)
+	c.Rest.Request.Params = params
+	return nil
+}
+
+// ParentConfig makes a REST stream the child of another REST stream declared in the same config.
+// The child's URL is requested (and paginated) once per parent record, with every {variable} in it
+// replaced by the value at KeyPaths[variable] in the parent record. The values are added to child
+// records as _sdc_parent, and up to Parallel parent records are requested concurrently.
+// A parent record without a value for a variable fails the stream unless SkipMissing is set.
+type ParentConfig struct {
+	Stream      string              `json:"stream,omitempty"`
+	KeyPaths    map[string][]string `json:"key_paths,omitempty"`
+	Parallel    int                 `json:"parallel,omitempty"`
+	SkipMissing bool                `json:"skip_missing,omitempty"`
+
+	Config *StreamConfig `json:"-"` // the parent stream's config, resolved by StreamsConfig.Create
+}
+
+// urlVariable matches the {variable} placeholders of a child stream URL
+var urlVariable = regexp.MustCompile(`{([^{}]+)}`)
+
+// URLVariables returns the names of the {variable} placeholders in a child stream URL
+func URLVariables(url string) []string {
+	var variables []string
+	for _, match := range urlVariable.FindAllStringSubmatch(url, -1) {
+		variables = append(variables, match[1])
+	}
+	return variables
+}
+
+// RenderURL replaces each {variable} placeholder of url using render, returning the first error
+func RenderURL(url string, render func(variable string) (string, error)) (string, error) {
+	var renderErr error
+	rendered := urlVariable.ReplaceAllStringFunc(url, func(placeholder string) string {
+		value, err := render(placeholder[1 : len(placeholder)-1])
+		if err != nil && renderErr == nil {
+			renderErr = err
+		}
+		return value
+	})
+	return rendered, renderErr
+}
+
+// validate checks the parent settings of a child stream with the given URL
+func (p *ParentConfig) validate(url string) error {
+	if p.Stream == "" {
+		if len(p.KeyPaths) > 0 || p.Parallel != 0 || p.SkipMissing {
+			return fmt.Errorf("rest.parent.stream is required for rest.parent")
+		}
+		return nil
+	}
+	if p.Config == nil {
+		return fmt.Errorf("rest.parent.stream %q not resolved; parent streams must be declared in the same config", p.Stream)
+	}
+	if p.Config.SourceType != "rest" {
+		return fmt.Errorf("rest.parent.stream %q must be a rest stream, got source_type %q", p.Stream, p.Config.SourceType)
+	}
+	if p.Parallel < 0 || p.Parallel > MaxConcurrencyLimit {
+		return fmt.Errorf("rest.parent.parallel must be between 1 and %d (or omitted for one parent at a time), got %d", MaxConcurrencyLimit, p.Parallel)
+	}
+
+	variables := URLVariables(url)
+	if len(variables) == 0 {
+		return fmt.Errorf("url of a child stream must contain at least one {variable} from rest.parent.key_paths")
+	}
+	for _, variable := range variables {
+		if len(p.KeyPaths[variable]) == 0 {
+			return fmt.Errorf("url variable {%s} has no path in rest.parent.key_paths", variable)
+		}
+		if util.PathMatchesMany(p.KeyPaths[variable]) {
+			return fmt.Errorf("rest.parent.key_paths path of {%s} must not use wildcards (\"*\" or \"**\")", variable)
+		}
+	}
+
+	if err := p.Config.Validate(); err != nil {
+		return fmt.Errorf("parent stream %q: %w", p.Stream, err)
+	}
+	return nil
 }
 
 type RestConfig struct {
-	Auth     AuthConfig     `json:"auth,omitempty"`
-	Response ResponseConfig `json:"response,omitempty"`
+	Request     RequestConfig     `json:"request,omitempty"`
+	Parent      ParentConfig      `json:"parent,omitempty"`
+	Replication ReplicationConfig `json:"replication,omitempty"`
+	Auth        AuthConfig        `json:"auth,omitempty"`
+	Response    ResponseConfig    `json:"response,omitempty"`
+	Retry       RetryConfig       `json:"retry,omitempty"`
+	RateLimit   RateLimitConfig   `json:"rate_limit,omitempty"`
+}
+
+// GraphQLConfig describes a GraphQL query POSTed with its variables to the stream's URL.
+// Records are read from RecordsPath (edges are unwrapped to their node), and Relay-style
+// connections are paginated by sending pageInfo.endCursor as the CursorVariable while
+// pageInfo.hasNextPage is true. PageInfoPath defaults to the pageInfo beside the records.
+type GraphQLConfig struct {
+	Query          string                 `json:"query,omitempty"`
+	Variables      map[string]interface{} `json:"variables,omitempty"`
+	RecordsPath    []string               `json:"records_path,omitempty"`
+	PageInfoPath   []string               `json:"page_info_path,omitempty"`
+	CursorVariable string                 `json:"cursor_variable,omitempty"`
+	Headers        map[string]string      `json:"headers,omitempty"`
+	Auth           AuthConfig             `json:"auth,omitempty"`
+	Retry          RetryConfig            `json:"retry,omitempty"`
+	RateLimit      RateLimitConfig        `json:"rate_limit,omitempty"`
+}
+
+// PageInfoPathOrDefault returns the path to the connection's pageInfo, defaulting to the pageInfo beside the records
+// (e.g. ["data", "repository", "issues", "pageInfo"] for records at ["data", "repository", "issues", "nodes"])
+func (g GraphQLConfig) PageInfoPathOrDefault() []string {
+	if len(g.PageInfoPath) > 0 || len(g.RecordsPath) == 0 {
+		return g.PageInfoPath
+	}
+	path := append([]string{}, g.RecordsPath[:len(g.RecordsPath)-1]...)
+	return append(path, "pageInfo")
+}
+
+// CursorVariableName returns the query variable the end cursor is sent as, defaulting to "after"
+func (g GraphQLConfig) CursorVariableName() string {
+	if g.CursorVariable == "" {
+		return "after"
+	}
+	return g.CursorVariable
+}
+
+// validate checks the GraphQL settings
+func (g *GraphQLConfig) validate() error {
+	if strings.TrimSpace(g.Query) == "" {
+		return fmt.Errorf("graphql.query is required for graphql streams")
+	}
+	if len(g.RecordsPath) == 0 {
+		return fmt.Errorf("graphql.records_path is required for graphql streams")
+	}
+	if g.Auth.Required && g.Auth.Strategy == "oauth" {
+		if err := g.Auth.OAuth.validate(); err != nil {
+			return err
+		}
+	}
+	if err := g.Retry.validate(); err != nil {
+		return err
+	}
+	return g.RateLimit.validate()
+}
+
+// RESTConfig returns the HTTP settings of the GraphQL stream as a RestConfig, so its requests are sent,
+// authenticated, retried and rate limited exactly as REST requests are
+func (g GraphQLConfig) RESTConfig() RestConfig {
+	return RestConfig{
+		Request:   RequestConfig{Method: "POST", Headers: g.Headers},
+		Auth:      g.Auth,
+		Retry:     g.Retry,
+		RateLimit: g.RateLimit,
+	}
+}
+
+type DBConfig struct {
+	Driver    string `json:"driver,omitempty"`
+	DSN       string `json:"dsn,omitempty"`
+	Table     string `json:"table,omitempty"`
+	Query     string `json:"query,omitempty"`
+	BatchSize int    `json:"batch_size,omitempty"`
+}
+
+// DBDrivers lists the supported values of db.driver
+var DBDrivers = []string{"mysql", "postgres", "sqlite"}
+
+// validate checks the db settings, so a config that could never connect or query fails before extraction
+func (d *DBConfig) validate() error {
+	supported := false
+	for _, driver := range DBDrivers {
+		supported = supported || d.Driver == driver
+	}
+	if !supported {
+		return fmt.Errorf("unsupported db.driver %q; expected one of %v", d.Driver, DBDrivers)
+	}
+	if d.DSN == "" {
+		return fmt.Errorf("db.dsn is required")
+	}
+	if d.Table != "" && d.Query != "" {
+		return fmt.Errorf("db.table and db.query are mutually exclusive")
+	}
+	if strings.TrimSpace(d.Table) == "" && strings.TrimSpace(d.Query) == "" {
+		return fmt.Errorf("db.table or db.query is required")
+	}
+	if d.BatchSize < 0 {
+		return fmt.Errorf("db.batch_size must not be negative, got %d", d.BatchSize)
+	}
+	return nil
+}
+
+type HTMLConfig struct {
+	TableSelector   string `json:"table_selector,omitempty"`
+	TableIndex      int    `json:"table_index,omitempty"`
+	HeaderRows      int    `json:"header_rows,omitempty"`
+	HeaderSeparator string `json:"header_separator,omitempty"`
+	Colspan         string `json:"colspan,omitempty"`
+	AnchorValue     string `json:"anchor_value,omitempty"`
+}
+
+// validate checks the table settings, including the colspan and anchor_value modes
+func (h *HTMLConfig) validate() error {
+	if h.TableIndex < 0 {
+		return fmt.Errorf("html.table_index must not be negative, got %d", h.TableIndex)
+	}
+	if h.HeaderRows < 0 {
+		return fmt.Errorf("html.header_rows must not be negative, got %d", h.HeaderRows)
+	}
+	if h.Colspan != "" && h.Colspan != "repeat" && h.Colspan != "first" {
+		return fmt.Errorf("unsupported html.colspan %q; expected repeat or first", h.Colspan)
+	}
+	if h.AnchorValue != "" && h.AnchorValue != "text" && h.AnchorValue != "href" {
+		return fmt.Errorf("unsupported html.anchor_value %q; expected text or href", h.AnchorValue)
+	}
+	return nil
 }
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateGetRequestBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		rest    RestConfig
+		wantErr bool
+	}{
+		{"GET without a body", RestConfig{Request: RequestConfig{Method: "GET"}}, false},
+		{"body without a method", RestConfig{Request: RequestConfig{Body: map[string]interface{}{"q": "x"}}}, false},
+		{"POST with a body", RestConfig{Request: RequestConfig{Method: "post", Body: map[string]interface{}{"q": "x"}}}, false},
+		{"GET with a body", RestConfig{Request: RequestConfig{Method: "get", Body: map[string]interface{}{"q": "x"}}}, true},
+		{"GET paginating in the body", RestConfig{
+			Request:  RequestConfig{Method: "GET"},
+			Response: ResponseConfig{Pagination: true, PaginationStrategy: "query", PaginationQuery: PaginationQueryConfig{QueryParameter: "page", QueryValue: 2, QueryIncrement: 1, Location: "body"}},
+		}, true},
+		{"GET paginating in the query", RestConfig{
+			Request:  RequestConfig{Method: "GET"},
+			Response: ResponseConfig{Pagination: true, PaginationStrategy: "query", PaginationQuery: PaginationQueryConfig{QueryParameter: "page", QueryValue: 2, QueryIncrement: 1}},
+		}, false},
+	}
+
+	for _, test := range tests {
+		config := StreamConfig{SourceType: "rest", URL: "https://example.com/records", Rest: test.rest}
+		config.Records.UniqueKeyPath = []string{"id"}
+
+		err := config.Validate()
+		if test.wantErr && (err == nil || !strings.Contains(err.Error(), "GET")) {
+			t.Errorf("%s: got error %v, want a GET body error", test.name, err)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateDBConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      DBConfig
+		wantErr string
+	}{
+		{"table", DBConfig{Driver: "sqlite", DSN: "records.db", Table: "users"}, ""},
+		{"query", DBConfig{Driver: "postgres", DSN: "postgres://localhost/db", Query: "SELECT 1"}, ""},
+		{"unsupported driver", DBConfig{Driver: "oracle", DSN: "x", Table: "users"}, "db.driver"},
+		{"missing dsn", DBConfig{Driver: "mysql", Table: "users"}, "db.dsn"},
+		{"table and query", DBConfig{Driver: "sqlite", DSN: "records.db", Table: "users", Query: "SELECT 1"}, "mutually exclusive"},
+		{"neither table nor query", DBConfig{Driver: "sqlite", DSN: "records.db", Query: "  "}, "db.table or db.query"},
+		{"negative batch size", DBConfig{Driver: "postgres", DSN: "x", Table: "users", BatchSize: -1}, "db.batch_size"},
+	}
+
+	for _, test := range tests {
+		config := StreamConfig{SourceType: "db", DB: test.db}
+		config.Records.UniqueKeyPath = []string{"id"}
+
+		err := config.Validate()
+		if test.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("%s: got error %v, want an error containing %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestValidateReplicationKeyNotDroppedOrMasked(t *testing.T) {
+	tests := []struct {
+		name    string
+		records RecordsConfig
+		wantErr bool
+	}{
+		{"dropped", RecordsConfig{DropFieldPaths: [][]string{{"meta", "updated_at"}}}, true},
+		{"parent dropped", RecordsConfig{DropFieldPaths: [][]string{{"meta"}}}, true},
+		{"dropped at any depth", RecordsConfig{DropFieldPaths: [][]string{{"**", "updated_at"}}}, true},
+		{"hashed by wildcard", RecordsConfig{SensitiveFieldPaths: [][]string{{"*", "updated_at"}}}, true},
+		{"masked", RecordsConfig{SensitiveFields: []SensitiveFieldConfig{{Path: []string{"meta", "*"}, Strategy: "null"}}}, true},
+		{"dotted member dropped", RecordsConfig{DropFieldPaths: [][]string{{"meta.updated_at"}}}, false},
+		{"sibling dropped", RecordsConfig{DropFieldPaths: [][]string{{"meta", "created_at"}}}, false},
+		{"top-level namesake dropped", RecordsConfig{DropFieldPaths: [][]string{{"updated_at"}}}, false},
+	}
+
+	for _, test := range tests {
+		config := StreamConfig{SourceType: "jsonl", URL: "records.jsonl", Records: test.records}
+		config.Records.UniqueKeyPath = []string{"id"}
+		config.Records.ReplicationKeyPath = []string{"meta", "updated_at"}
+
+		err := config.Validate()
+		if test.wantErr && (err == nil || !strings.Contains(err.Error(), "replication_key_path")) {
+			t.Errorf("%s: got error %v, want a replication_key_path error", test.name, err)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateOAuthGrants(t *testing.T) {
+	tests := []struct {
+		name    string
+		oauth   OAuthConfig
+		wantErr string
+	}{
+		{"refresh token", OAuthConfig{RefreshToken: "r"}, ""},
+		{"missing refresh token", OAuthConfig{}, "refresh_token"},
+		{"client credentials", OAuthConfig{GrantType: "client_credentials"}, ""},
+		{"password", OAuthConfig{GrantType: "password", Username: "u", Password: "p"}, ""},
+		{"password without a username", OAuthConfig{GrantType: "password", Password: "p"}, "username"},
+		{"password without a password", OAuthConfig{GrantType: "password", Username: "u"}, "password"},
+		{"unknown grant", OAuthConfig{GrantType: "implicit"}, "grant_type"},
+	}
+
+	for _, test := range tests {
+		test.oauth.TokenURL = "https://example.com/token"
+		err := test.oauth.validate()
+		if test.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("%s: got error %v, want a %s error", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestStreamsConfigParents(t *testing.T) {
+	const repos = `{"stream_name": "repos", "source_type": "rest", "url": "https://api.example.com/repos", "records": {"unique_key_path": ["id"]}}`
+	child := func(url string, parent string) string {
+		return `{"stream_name": "commits", "source_type": "rest", "url": "` + url + `", "records": {"unique_key_path": ["sha"]}, "rest": {"parent": ` + parent + `}}`
+	}
+
+	tests := []struct {
+		name      string
+		streams   []string
+		createErr string
+		validErr  string
+	}{
+		{"resolved", []string{repos, child("https://api.example.com/repos/{name}/commits", `{"stream": "repos", "key_paths": {"name": ["name"]}}`)}, "", ""},
+		{"parent declared after the child", []string{child("https://api.example.com/repos/{name}/commits", `{"stream": "repos", "key_paths": {"name": ["name"]}}`), repos}, "", ""},
+		{"parent not found", []string{child("https://api.example.com/repos/{name}/commits", `{"stream": "repositories", "key_paths": {"name": ["name"]}}`)}, "not found", ""},
+		{
+			"cycle",
+			[]string{
+				`{"stream_name": "a", "source_type": "rest", "url": "https://api.example.com/a/{id}", "rest": {"parent": {"stream": "b", "key_paths": {"id": ["id"]}}}}`,
+				`{"stream_name": "b", "source_type": "rest", "url": "https://api.example.com/b/{id}", "rest": {"parent": {"stream": "a", "key_paths": {"id": ["id"]}}}}`,
+			},
+			"cycle", "",
+		},
+		{"url without variables", []string{repos, child("https://api.example.com/commits", `{"stream": "repos", "key_paths": {"name": ["name"]}}`)}, "", "at least one {variable}"},
+		{"variable without a key path", []string{repos, child("https://api.example.com/repos/{owner}/{name}", `{"stream": "repos", "key_paths": {"name": ["name"]}}`)}, "", "{owner}"},
+		{"wildcard key path", []string{repos, child("https://api.example.com/repos/{name}", `{"stream": "repos", "key_paths": {"name": ["**", "name"]}}`)}, "", "wildcards"},
+		{"negative parallel", []string{repos, child("https://api.example.com/repos/{name}", `{"stream": "repos", "key_paths": {"name": ["name"]}, "parallel": -1}`)}, "", "parallel"},
+		{"parent settings without a parent", []string{child("https://api.example.com/repos/{name}", `{"skip_missing": true}`)}, "", "rest.parent.stream is required"},
+		{
+			"parent not a rest stream",
+			[]string{
+				`{"stream_name": "repos", "source_type": "csv", "url": "repos.csv", "records": {"unique_key_path": ["id"]}}`,
+				child("https://api.example.com/repos/{name}", `{"stream": "repos", "key_paths": {"name": ["name"]}}`),
+			},
+			"", "must be a rest stream",
+		},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(`{"streams": [`+strings.Join(test.streams, ",")+`]}`), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var config StreamsConfig
+		err := config.Create(path)
+		if test.createErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.createErr) {
+				t.Errorf("%s: got error %v, want a %q error", test.name, err, test.createErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		commits, err := config.Select([]string{"commits"})
+		if err != nil || len(commits) != 1 {
+			t.Fatalf("%s: got %d streams and error %v selecting commits", test.name, len(commits), err)
+		}
+		if parent := commits[0].Rest.Parent; parent.Stream != "" && (parent.Config == nil || parent.Config.StreamName != parent.Stream) {
+			t.Errorf("%s: rest.parent %q resolved to %v", test.name, parent.Stream, parent.Config)
+		}
+
+		err = commits[0].Validate()
+		if test.validErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if test.validErr != "" && (err == nil || !strings.Contains(err.Error(), test.validErr)) {
+			t.Errorf("%s: got error %v, want a %q error", test.name, err, test.validErr)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"math/big"
+)
+
+// ff1 is the FF1 format-preserving encryption mode of NIST SP 800-38G: a keyed permutation of the numeral
+// strings of a radix, of each length from 2. Strings shorter than the standard's minimum domain (a million
+// values) are still permuted, but a permutation of so few values protects little.
+type ff1 struct {
+	block cipher.Block
+	radix int
+}
+
+// newFF1 returns FF1 with AES for numerals of radix (2 to 65536) under an AES-128, 192 or 256 key
+func newFF1(key []byte, radix int) (*ff1, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ff1{block: block, radix: radix}, nil
+}
+
+// encrypt returns the FF1 encryption of numerals (each less than the radix, at least 2 of them) under tweak
+func (f *ff1) encrypt(numerals []uint16, tweak []byte) []uint16 {
+	n, t := len(numerals), len(tweak)
+	u := n / 2
+	v := n - u
+	a := append([]uint16{}, numerals[:u]...)
+	b := append([]uint16{}, numerals[u:]...)
+
+	radix := big.NewInt(int64(f.radix))
+	modU := new(big.Int).Exp(radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(radix, big.NewInt(int64(v)), nil)
+	byteLength := (new(big.Int).Sub(modV, big.NewInt(1)).BitLen() + 7) / 8
+	digestLength := 4*((byteLength+3)/4) + 4
+
+	p := []byte{1, 2, 1, byte(f.radix >> 16), byte(f.radix >> 8), byte(f.radix), 10, byte(u % 256), 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(p[8:12], uint32(n))
+	binary.BigEndian.PutUint32(p[12:16], uint32(t))
+
+	padding := ((-t-byteLength-1)%16 + 16) % 16
+	q := make([]byte, t+padding+1+byteLength)
+	copy(q, tweak)
+
+	for i := 0; i < 10; i++ {
+		q[t+padding] = byte(i)
+		f.num(b).FillBytes(q[t+padding+1:])
+		r := f.prf(append(append([]byte{}, p...), q...))
+
+		s := append([]byte{}, r...)
+		for j := 1; len(s) < digestLength; j++ {
+			block := append([]byte{}, r...)
+			binary.BigEndian.PutUint64(block[8:], binary.BigEndian.Uint64(r[8:])^uint64(j))
+			f.block.Encrypt(block, block)
+			s = append(s, block...)
+		}
+		y := new(big.Int).SetBytes(s[:digestLength])
+
+		m, mod := u, modU
+		if i%2 == 1 {
+			m, mod = v, modV
+		}
+		c := new(big.Int).Add(f.num(a), y)
+		c.Mod(c, mod)
+		a, b = b, f.str(c, m)
+	}
+	return append(a, b...)
+}
+
+// prf is the CBC-MAC of data, a whole number of AES blocks
+func (f *ff1) prf(data []byte) []byte {
+	y := make([]byte, aes.BlockSize)
+	for i := 0; i < len(data); i += aes.BlockSize {
+		for j := range y {
+			y[j] ^= data[i+j]
+		}
+		f.block.Encrypt(y, y)
+	}
+	return y
+}
+
+// num returns the number numerals represent, most significant first
+func (f *ff1) num(numerals []uint16) *big.Int {
+	radix, x := big.NewInt(int64(f.radix)), new(big.Int)
+	for _, numeral := range numerals {
+		x.Mul(x, radix)
+		x.Add(x, big.NewInt(int64(numeral)))
+	}
+	return x
+}
+
+// str returns the m numerals representing x, most significant first
+func (f *ff1) str(x *big.Int, m int) []uint16 {
+	radix, remainder := big.NewInt(int64(f.radix)), new(big.Int)
+	x = new(big.Int).Set(x)
+	numerals := make([]uint16, m)
+	for i := m - 1; i >= 0; i-- {
+		x.DivMod(x, radix, remainder)
+		numerals[i] = uint16(remainder.Int64())
+	}
+	return numerals
+}
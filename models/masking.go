@@ -0,0 +1,172 @@
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	util "github.com/5amCurfew/xtkt/util"
+)
+
+// Mask returns the value of a sensitive field masked by the field's strategy
+func (f SensitiveFieldConfig) Mask(value interface{}) (interface{}, error) {
+	switch f.StrategyName() {
+	case "sha256":
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%v", value)))
+		return hex.EncodeToString(hash[:]), nil
+	case "null":
+		return nil, nil
+	}
+
+	text, err := maskingInput(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f.StrategyName() {
+	case "hmac":
+		mac := hmac.New(sha256.New, f.key())
+		mac.Write([]byte(text))
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	case "tokenise":
+		return f.tokenise(text)
+	case "mask":
+		return maskCharacters(text, f.maskChar(), f.KeepLast), nil
+	case "mask_email":
+		at := strings.LastIndex(text, "@")
+		if at < 0 {
+			return maskCharacters(text, f.maskChar(), 0), nil
+		}
+		return strings.Repeat(f.maskChar(), utf8.RuneCountInString(text[:at])) + text[at:], nil
+	default:
+		return nil, fmt.Errorf("unsupported masking strategy %q", f.Strategy)
+	}
+}
+
+func (f SensitiveFieldConfig) key() []byte {
+	return []byte(os.Getenv(f.KeyEnvName()))
+}
+
+func (f SensitiveFieldConfig) maskChar() string {
+	if f.MaskChar == "" {
+		return "*"
+	}
+	return f.MaskChar
+}
+
+// maskingInput returns the text masked for a value: strings as they are, numbers and booleans as
+// bookmark keys are written (so 1 and "1" mask alike) and objects and arrays as canonical JSON
+func maskingInput(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		encoded, err := util.CanonicalJSON(v)
+		if err != nil {
+			return "", fmt.Errorf("error masking value: %w", err)
+		}
+		return string(encoded), nil
+	default:
+		return util.ToKeyString(v), nil
+	}
+}
+
+// tokenAlphabets are the character classes tokenise permutes, each within itself
+var tokenAlphabets = []string{"0123456789", "abcdefghijklmnopqrstuvwxyz", "ABCDEFGHIJKLMNOPQRSTUVWXYZ"}
+
+// tokenise replaces the ASCII digits, lower-case and upper-case letters of text by FF1 encryption (under an AES-256
+// key derived from the masking key), keeping its length, case and separators. Each class is encrypted as one
+// numeral string, tweaked by the layout of text and the classes already encrypted, so distinct texts always
+// have distinct tokens. Tokens are strings, even of numbers (e.g. leading zeros are kept).
+func (f SensitiveFieldConfig) tokenise(text string) (string, error) {
+	key := sha256.Sum256(f.key())
+	token := []rune(text)
+
+	// The tweak starts as the layout of text: every character of a class replaced by the class's first
+	layout := make([]rune, len(token))
+	for i, r := range token {
+		layout[i] = r
+		for _, alphabet := range tokenAlphabets {
+			if strings.ContainsRune(alphabet, r) {
+				layout[i] = rune(alphabet[0])
+			}
+		}
+	}
+	tweak := []byte(string(layout))
+
+	for _, alphabet := range tokenAlphabets {
+		var positions []int
+		var numerals []uint16
+		for i, r := range token {
+			if numeral := strings.IndexRune(alphabet, r); numeral >= 0 {
+				positions = append(positions, i)
+				numerals = append(numerals, uint16(numeral))
+			}
+		}
+
+		switch len(numerals) {
+		case 0:
+			continue
+		case 1:
+			// FF1 needs at least two numerals, so a single one is mapped by a keyed shuffle of the alphabet
+			numerals[0] = shuffleNumeral(key[:], tweak, len(alphabet), numerals[0])
+		default:
+			cipher, err := newFF1(key[:], len(alphabet))
+			if err != nil {
+				return "", fmt.Errorf("error tokenising value: %w", err)
+			}
+			numerals = cipher.encrypt(numerals, tweak)
+		}
+
+		for i, position := range positions {
+			token[position] = rune(alphabet[numerals[i]])
+			tweak = append(tweak, alphabet[numerals[i]])
+		}
+	}
+	return string(token), nil
+}
+
+// shuffleNumeral maps numeral by the permutation of 0 to radix-1 that orders each by its HMAC-SHA256 under key and tweak
+func shuffleNumeral(key []byte, tweak []byte, radix int, numeral uint16) uint16 {
+	macs := make([][]byte, radix)
+	order := make([]uint16, radix)
+	for i := range order {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(tweak)
+		mac.Write([]byte{byte(radix), byte(i)})
+		macs[i], order[i] = mac.Sum(nil), uint16(i)
+	}
+	sort.Slice(order, func(i, j int) bool { return bytes.Compare(macs[order[i]], macs[order[j]]) < 0 })
+	return order[numeral]
+}
+
+// maskCharacters replaces every letter and digit of text with maskChar except the last keepLast, keeping separators
+func maskCharacters(text string, maskChar string, keepLast int) string {
+	alphanumeric := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			alphanumeric++
+		}
+	}
+
+	var masked strings.Builder
+	seen := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			seen++
+			if seen <= alphanumeric-keepLast {
+				masked.WriteString(maskChar)
+				continue
+			}
+		}
+		masked.WriteRune(r)
+	}
+	return masked.String()
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// FF1 samples of NIST SP 800-38G (AES-128 and AES-256)
+func TestFF1Samples(t *testing.T) {
+	tests := []struct {
+		key, tweak, alphabet, plaintext, ciphertext string
+	}{
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "", "0123456789", "0123456789", "2433477484"},
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "39383736353433323130", "0123456789", "0123456789", "6124200773"},
+		{"2B7E151628AED2A6ABF7158809CF4F3C", "3737373770717273373737", "0123456789abcdefghijklmnopqrstuvwxyz", "0123456789abcdefghi", "a9tv40mll9kdu509eum"},
+		{"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "", "0123456789", "0123456789", "6657667009"},
+		{"2B7E151628AED2A6ABF7158809CF4F3CEF4359D8D580AA4F7F036D6F04FC6A94", "3737373770717273373737", "0123456789abcdefghijklmnopqrstuvwxyz", "0123456789abcdefghi", "xs8a0azh2avyalyzuwd"},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		tweak, _ := hex.DecodeString(test.tweak)
+		cipher, err := newFF1(key, len(test.alphabet))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		numerals := make([]uint16, len(test.plaintext))
+		for i, r := range test.plaintext {
+			numerals[i] = uint16(strings.IndexRune(test.alphabet, r))
+		}
+		var got strings.Builder
+		for _, numeral := range cipher.encrypt(numerals, tweak) {
+			got.WriteByte(test.alphabet[numeral])
+		}
+		if got.String() != test.ciphertext {
+			t.Errorf("key %s tweak %q: got %s, want %s", test.key, test.tweak, got.String(), test.ciphertext)
+		}
+	}
+}
+
+func TestTokenisePreservesFormat(t *testing.T) {
+	t.Setenv(DefaultMaskingKeyEnv, "test-key")
+	field := SensitiveFieldConfig{Path: []string{"id"}, Strategy: "tokenise"}
+
+	for _, value := range []interface{}{"07", "AB-123-cd", "user@example.com", "x", "Ünïcode 42", 1234.5, float64(7)} {
+		masked, err := field.Mask(value)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", value, err)
+		}
+		token, ok := masked.(string)
+		if !ok {
+			t.Fatalf("%v: token %v is a %T, not a string", value, masked, masked)
+		}
+
+		text, _ := maskingInput(value)
+		if len([]rune(token)) != len([]rune(text)) {
+			t.Errorf("%v: token %q has a different length", value, token)
+			continue
+		}
+		tokenRunes := []rune(token)
+		for i, r := range []rune(text) {
+			switch {
+			case r < unicode.MaxASCII && unicode.IsDigit(r):
+				if !unicode.IsDigit(tokenRunes[i]) {
+					t.Errorf("%v: token %q does not keep the digit at %d", value, token, i)
+				}
+			case r < unicode.MaxASCII && unicode.IsLower(r):
+				if !unicode.IsLower(tokenRunes[i]) {
+					t.Errorf("%v: token %q does not keep the lower-case letter at %d", value, token, i)
+				}
+			case r < unicode.MaxASCII && unicode.IsUpper(r):
+				if !unicode.IsUpper(tokenRunes[i]) {
+					t.Errorf("%v: token %q does not keep the upper-case letter at %d", value, token, i)
+				}
+			default:
+				if tokenRunes[i] != r {
+					t.Errorf("%v: token %q does not keep %q at %d", value, token, r, i)
+				}
+			}
+		}
+
+		again, _ := field.Mask(value)
+		if again != masked {
+			t.Errorf("%v: tokenised as %v then %v", value, masked, again)
+		}
+	}
+}
+
+func TestTokeniseIsInjective(t *testing.T) {
+	t.Setenv(DefaultMaskingKeyEnv, "test-key")
+	field := SensitiveFieldConfig{Path: []string{"id"}, Strategy: "tokenise"}
+
+	tests := []struct {
+		name   string
+		values []string
+	}{
+		{"one digit", numbers(0, 9, "%d")},
+		{"two digits", numbers(10, 99, "%d")},
+		{"zero-padded", numbers(0, 999, "%03d")},
+		{"mixed classes", []string{"a1", "a2", "b1", "b2", "A1", "Z9", "a-1", "a_1"}},
+	}
+
+	for _, test := range tests {
+		seen := map[string]string{}
+		for _, value := range test.values {
+			masked, err := field.Mask(value)
+			if err != nil {
+				t.Fatalf("%s: %q: unexpected error: %v", test.name, value, err)
+			}
+			token := masked.(string)
+			if previous, exists := seen[token]; exists {
+				t.Errorf("%s: %q and %q both tokenise to %q", test.name, previous, value, token)
+			}
+			seen[token] = value
+		}
+	}
+}
+
+// numbers formats every integer from first to last
+func numbers(first int, last int, format string) []string {
+	values := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		values = append(values, fmt.Sprintf(format, i))
+	}
+	return values
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"io"
+)
+
 type Message struct {
 	Type               string                 `json:"type"`
 	Record             map[string]interface{} `json:"record,omitempty"`
@@ -10,3 +15,15 @@ type Message struct {
 	BookmarkProperties []string               `json:"bookmark_properties,omitempty"`
 	Required           []string               `json:"required,omitempty"`
 }
+
+// writeMessage serialises a message as a single line and writes it in one call,
+// so messages from concurrent streams sharing a synchronised writer never interleave
+func writeMessage(w io.Writer, message Message) error {
+	messageJson, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(messageJson, '\n'))
+	return err
+}
@@ -1,13 +1,16 @@
 package models
 
+import "io"
+
 // Model represents a persistable entity with common lifecycle operations.
 // All models that implement this interface provide consistent methods for
 // creation, reading, updating, and message generation.
 // The Create method accepts variadic interface{} parameters for flexibility,
 // allowing each implementation to define what source data it needs.
+// Message writes Singer messages to the supplied writer rather than a shared stdout.
 type Model interface {
 	Create(source ...interface{}) error
 	Read() error
 	Update() error
-	Message() error
+	Message(w io.Writer) error
 }
@@ -3,20 +3,18 @@ package models
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 
 	util "github.com/5amCurfew/xtkt/util"
 	log "github.com/sirupsen/logrus"
 )
 
-// Compile-time verification that Record implements Model interface
-var _ Model = (*Record)(nil)
-
 // Record represents a data record with transformation capabilities.
 // It provides a type-safe wrapper around map[string]interface{} with
 // convenient accessor methods, transformation logic, and message generation.
+// Unlike the persisted models, its Update and Message methods take the stream's
+// configuration explicitly, as a map-backed type cannot carry it.
 type Record map[string]interface{}
 
 // Create initialises the Record from a source.
@@ -45,68 +43,208 @@ func (r *Record) Read() error {
 
 // Update applies transformations to the record including dropping fields,
 // hashing sensitive fields, and generating surrogate keys
-func (r Record) Update() error {
-	if util.GetValueAtPath(Config.Records.UniqueKeyPath, r) == nil {
-		return fmt.Errorf("unique_key field path not found in record")
-	}
-
-	if util.IsEmpty(util.GetValueAtPath(Config.Records.UniqueKeyPath, r)) {
-		return fmt.Errorf("unique_key null or empty in record")
+func (r Record) Update(config *RecordsConfig) error {
+	if err := r.validateNaturalKey(config); err != nil {
+		return err
 	}
 
 	// Drop fields if configured
-	if Config.Records.DropFieldPaths != nil {
-		for _, path := range Config.Records.DropFieldPaths {
+	if config.DropFieldPaths != nil {
+		for _, path := range config.DropFieldPaths {
 			util.DropFieldAtPath(path, r)
 		}
 	}
 
 	// Hash sensitive fields if configured
-	if Config.Records.SensitiveFieldPaths != nil {
-		for _, path := range Config.Records.SensitiveFieldPaths {
-			if fieldValue := util.GetValueAtPath(path, r); fieldValue != nil {
+	if config.SensitiveFieldPaths != nil {
+		for _, path := range config.SensitiveFieldPaths {
+			hashed, _ := util.UpdateValuesAtPath(path, r, func(fieldValue interface{}) (interface{}, error) {
 				hash := sha256.Sum256([]byte(fmt.Sprintf("%v", fieldValue)))
-				util.SetValueAtPath(path, r, hex.EncodeToString(hash[:]))
-			} else {
+				return hex.EncodeToString(hash[:]), nil
+			})
+			if hashed == 0 {
 				log.WithFields(log.Fields{
 					"sensitive_field_path": path,
-					"_sdc_natural_key":     util.ToKeyString(util.GetValueAtPath(Config.Records.UniqueKeyPath, r)),
+					"_sdc_natural_key":     util.NaturalKeyString(r.NaturalKey(config)),
 				}).Warn("sensitive field path not found; skipping hash")
 			}
 		}
 	}
 
-	// Generate surrogate key fields
+	// Mask sensitive fields by their configured strategies
+	for _, field := range config.SensitiveFields {
+		masked, err := util.UpdateValuesAtPath(field.Path, r, field.Mask)
+		if err != nil {
+			return fmt.Errorf("error masking sensitive field %v: %w", field.Path, err)
+		}
+		if masked == 0 {
+			log.WithFields(log.Fields{
+				"sensitive_field_path": field.Path,
+				"_sdc_natural_key":     util.NaturalKeyString(r.NaturalKey(config)),
+			}).Warn("sensitive field path not found; skipping mask")
+		}
+	}
+
+	// Generate surrogate key fields, from the fields change detection considers
+	fields, err := util.CanonicalJSON(r.changeDetectionFields(config))
+	if err != nil {
+		return fmt.Errorf("error hashing record: %w", err)
+	}
 	h := sha256.New()
-	h.Write([]byte(util.ToString(r)))
+	h.Write(fields)
 
 	// Store natural key as its original type
-	r["_sdc_natural_key"] = util.GetValueAtPath(Config.Records.UniqueKeyPath, r)
+	r["_sdc_natural_key"] = r.NaturalKey(config)
 	r["_sdc_surrogate_key"] = hex.EncodeToString(h.Sum(nil))
 	r["_sdc_timestamp"] = util.NowTimestamp()
 
-	h.Write([]byte(util.ToString(r)))
+	record, err := util.CanonicalJSON(r.ToMap())
+	if err != nil {
+		return fmt.Errorf("error hashing record: %w", err)
+	}
+	h.Write(record)
 	r["_sdc_unique_key"] = hex.EncodeToString(h.Sum(nil))
 
 	return nil
 }
 
-// Message generates a RECORD type message and writes it to stdout
-func (r Record) Message() error {
+// SurrogateKeyVersion is the version of the _sdc_surrogate_key hash recorded in bookmark entries.
+// Version 1 (entries without a hash_version) hashed Go's %v formatting of the record, which depends on
+// Go's map and float printing and cannot tell "1" from 1; version 2 hashes RFC 8785 canonical JSON.
+const SurrogateKeyVersion = 2
+
+// legacySurrogateKey returns the version 1 surrogate key of a transformed record, so bookmark entries
+// written before version 2 can still be compared (and are then rewritten with the version 2 key).
+// Version 1 always hashed the whole record, whatever records.change_detection selects.
+func (r Record) legacySurrogateKey() string {
+	source := withoutFieldPaths(r, [][]string{{"_sdc_natural_key"}, {"_sdc_surrogate_key"}, {"_sdc_timestamp"}, {"_sdc_unique_key"}})
+	h := sha256.New()
+	h.Write([]byte(util.ToString(source)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// changeDetectionFields returns the fields hashed into _sdc_surrogate_key: the whole record by default,
+// otherwise the natural key with only the included fields or every field except the excluded ones.
+// Nested objects are copied where fields are excluded, so the record itself is never changed.
+func (r Record) changeDetectionFields(config *RecordsConfig) map[string]interface{} {
+	changeDetection := config.ChangeDetection
+	switch {
+	case len(changeDetection.IncludeFieldPaths) > 0:
+		fields := map[string]interface{}{"_sdc_natural_key": r.NaturalKey(config)}
+		for _, path := range changeDetection.IncludeFieldPaths {
+			if value := util.GetValueAtPath(path, r); value != nil {
+				util.SetValueAtPath(path, fields, value)
+			}
+		}
+		return fields
+	case len(changeDetection.ExcludeFieldPaths) > 0:
+		fields := withoutFieldPaths(r, changeDetection.ExcludeFieldPaths)
+		fields["_sdc_natural_key"] = r.NaturalKey(config)
+		return fields
+	default:
+		return r
+	}
+}
+
+// withoutFieldPaths returns a copy of input without the fields at paths, copying only the nested objects on those paths
+func withoutFieldPaths(input map[string]interface{}, paths [][]string) map[string]interface{} {
+	output := make(map[string]interface{}, len(input))
+	for key, value := range input {
+		excluded := false
+		var nestedPaths [][]string
+		for _, path := range paths {
+			if path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				excluded = true
+				break
+			}
+			nestedPaths = append(nestedPaths, path[1:])
+		}
+		if excluded {
+			continue
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok && len(nestedPaths) > 0 {
+			value = withoutFieldPaths(nested, nestedPaths)
+		}
+		output[key] = value
+	}
+	return output
+}
+
+// NaturalKey returns the value at unique_key_path or, for composite keys, an array of the value at each of unique_key_paths
+func (r Record) NaturalKey(config *RecordsConfig) interface{} {
+	if len(config.UniqueKeyPaths) == 0 {
+		return util.GetValueAtPath(config.UniqueKeyPath, r)
+	}
+
+	components := make([]interface{}, 0, len(config.UniqueKeyPaths))
+	for _, path := range config.UniqueKeyPaths {
+		components = append(components, util.GetValueAtPath(path, r))
+	}
+	return components
+}
+
+// validateNaturalKey rejects records whose unique key, or any component of a composite key, is missing or empty.
+// Composite key components may be 0 or false, but not null, "" or an empty array or object.
+func (r Record) validateNaturalKey(config *RecordsConfig) error {
+	if len(config.UniqueKeyPaths) == 0 {
+		if util.GetValueAtPath(config.UniqueKeyPath, r) == nil {
+			return fmt.Errorf("unique_key field path not found in record")
+		}
+
+		if util.IsEmpty(util.GetValueAtPath(config.UniqueKeyPath, r)) {
+			return fmt.Errorf("unique_key null or empty in record")
+		}
+		return nil
+	}
+
+	for _, path := range config.UniqueKeyPaths {
+		switch component := util.GetValueAtPath(path, r).(type) {
+		case nil:
+			return fmt.Errorf("unique_key_paths component %v not found in record", path)
+		case string, []interface{}, map[string]interface{}:
+			if util.IsEmpty(component) {
+				return fmt.Errorf("unique_key_paths component %v empty in record", path)
+			}
+		}
+	}
+	return nil
+}
+
+// CreateTombstone initialises the Record as a deletion marker for a natural key missing at source.
+// The last known surrogate key is retained so targets can identify the deleted version.
+func (r *Record) CreateTombstone(naturalKey interface{}, entry BookmarkEntry) error {
+	*r = Record{
+		"_sdc_natural_key":   naturalKey,
+		"_sdc_surrogate_key": entry.SurrogateKey,
+		"_sdc_deleted_at":    entry.DeletedAt,
+		"_sdc_timestamp":     util.NowTimestamp(),
+	}
+
+	tombstone, err := util.CanonicalJSON(r.ToMap())
+	if err != nil {
+		return fmt.Errorf("error hashing tombstone: %w", err)
+	}
+	h := sha256.Sum256(tombstone)
+	(*r)["_sdc_unique_key"] = hex.EncodeToString(h[:])
+	return nil
+}
+
+// Message generates a RECORD type message for the stream and writes it to w
+func (r Record) Message(w io.Writer, stream string) error {
 	message := Message{
 		Type:   "RECORD",
 		Record: r.ToMap(),
-		Stream: STREAM_NAME,
+		Stream: stream,
 	}
 
-	messageJson, err := json.Marshal(message)
-	if err != nil {
+	if err := writeMessage(w, message); err != nil {
 		return fmt.Errorf("error creating record message: %w", err)
 	}
 
-	os.Stdout.Write(messageJson)
-	os.Stdout.Write([]byte("\n"))
-
 	return nil
 }
 
@@ -125,22 +263,21 @@ func (r Record) ToMap() map[string]interface{} {
 	return map[string]interface{}(r)
 }
 
-// PassesBookmark checks if the record should be emitted based on the bookmark state.
+// PassesBookmark checks if the record should be emitted based on the previous bookmark.
 // Returns true if the record is new or has been updated since the last extraction.
-// Always returns true when FULL_REFRESH or DISCOVER_MODE is enabled.
-func (r Record) PassesBookmark() bool {
-	// Skip bookmark check if doing full refresh or discovery
-	if FULL_REFRESH || DISCOVER_MODE {
-		return true
-	}
-
+// Entries hashed by an earlier SurrogateKeyVersion are compared using that version's hash.
+// Callers skip the check entirely for full refresh and discovery runs.
+func (r Record) PassesBookmark(previous Bookmark, config *RecordsConfig) bool {
 	// Convert natural key to string for bookmark lookup (avoiding scientific notation)
-	key := util.ToKeyString(r["_sdc_natural_key"])
-	entry, exist := State.PreviousBookmark.Latest[key]
+	key := util.NaturalKeyString(r["_sdc_natural_key"])
+	entry, exist := previous.Latest[key]
 	currentSK := r["_sdc_surrogate_key"].(string)
 
-	if !exist {
-		return true // new record
+	if !exist || entry.DeletedAt != "" {
+		return true // new or previously deleted record
+	}
+	if entry.HashVersion < SurrogateKeyVersion {
+		currentSK = r.legacySurrogateKey()
 	}
 	return currentSK != entry.SurrogateKey // updated if _sdc_surrogate_key has changed
 }
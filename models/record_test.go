@@ -0,0 +1,138 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	util "github.com/5amCurfew/xtkt/util"
+)
+
+func TestRecordUpdateDropAndMask(t *testing.T) {
+	const source = `{
+		"id": 1,
+		"contacts": [{"email": "jo@example.com", "phone": "555-0100"}, {"email": "al@example.org", "phone": "555-0199"}],
+		"profile": {"ssn": "123-45-6789", "history": [{"ssn": "987-65-4321"}]}
+	}`
+
+	tests := []struct {
+		name    string
+		records RecordsConfig
+		field   string
+		want    string
+	}{
+		{
+			name: "drop and mask different members of every element",
+			records: RecordsConfig{
+				DropFieldPaths:  [][]string{{"contacts[*]", "phone"}},
+				SensitiveFields: []SensitiveFieldConfig{{Path: []string{"contacts[*]", "email"}, Strategy: "mask_email"}},
+			},
+			field: "contacts",
+			want:  `[{"email":"**@example.com"},{"email":"**@example.org"}]`,
+		},
+		{
+			name: "fields are dropped before they are masked",
+			records: RecordsConfig{
+				DropFieldPaths:  [][]string{{"contacts[0]"}},
+				SensitiveFields: []SensitiveFieldConfig{{Path: []string{"contacts[0]", "phone"}, Strategy: "mask", KeepLast: 2}},
+			},
+			field: "contacts",
+			want:  `[{"email":"al@example.org","phone":"***-**99"}]`,
+		},
+		{
+			name: "masking a dropped field is skipped",
+			records: RecordsConfig{
+				DropFieldPaths:  [][]string{{"contacts", "*", "email"}},
+				SensitiveFields: []SensitiveFieldConfig{{Path: []string{"contacts[*]", "email"}, Strategy: "null"}},
+			},
+			field: "contacts",
+			want:  `[{"phone":"555-0100"},{"phone":"555-0199"}]`,
+		},
+		{
+			name: "deep paths mask at every depth",
+			records: RecordsConfig{
+				SensitiveFields: []SensitiveFieldConfig{{Path: []string{"**", "ssn"}, Strategy: "mask", KeepLast: 4}},
+			},
+			field: "profile",
+			want:  `{"history":[{"ssn":"***-**-4321"}],"ssn":"***-**-6789"}`,
+		},
+		{
+			name: "deep drops leave the path to the dropped fields",
+			records: RecordsConfig{
+				DropFieldPaths: [][]string{{"**", "ssn"}},
+			},
+			field: "profile",
+			want:  `{"history":[{}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		var record Record
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(source), &data); err != nil {
+			t.Fatalf("invalid test record: %v", err)
+		}
+		if err := record.Create(data); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		test.records.UniqueKeyPath = []string{"id"}
+		if err := record.Update(&test.records); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		got, _ := json.Marshal(record[test.field])
+		if string(got) != test.want {
+			t.Errorf("%s: %s is %s, want %s", test.name, test.field, got, test.want)
+		}
+	}
+}
+
+func TestPassesBookmarkLegacyHash(t *testing.T) {
+	data := map[string]interface{}{"id": 1.0, "name": "a", "synced_at": "2024-01-01T00:00:00Z"}
+
+	// A version 1 entry hashed Go's formatting of the whole record
+	h := sha256.Sum256([]byte(util.ToString(data)))
+	previous := Bookmark{Latest: map[string]BookmarkEntry{"1": {SurrogateKey: hex.EncodeToString(h[:])}}}
+
+	for _, changeDetection := range []ChangeDetectionConfig{
+		{},
+		{ExcludeFieldPaths: [][]string{{"synced_at"}}},
+		{IncludeFieldPaths: [][]string{{"name"}}},
+	} {
+		record := Record{}
+		for key, value := range data {
+			record[key] = value
+		}
+		config := &RecordsConfig{UniqueKeyPath: []string{"id"}, ChangeDetection: changeDetection}
+		if err := record.Update(config); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if record.PassesBookmark(previous, config) {
+			t.Errorf("change detection %+v: unchanged record passes its version 1 bookmark", changeDetection)
+		}
+	}
+}
+
+func TestCreateTombstone(t *testing.T) {
+	var tombstone Record
+	if err := tombstone.CreateTombstone(42.0, BookmarkEntry{SurrogateKey: "abc", DeletedAt: "2024-01-01T00:00:00Z"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fields := map[string]interface{}{}
+	for key, value := range tombstone {
+		if key != "_sdc_unique_key" {
+			fields[key] = value
+		}
+	}
+	canonical, _ := util.CanonicalJSON(fields)
+	h := sha256.Sum256(canonical)
+	if tombstone["_sdc_unique_key"] != hex.EncodeToString(h[:]) {
+		t.Errorf("_sdc_unique_key %v is not the hash of the tombstone's canonical JSON", tombstone["_sdc_unique_key"])
+	}
+	if tombstone["_sdc_natural_key"] != 42.0 || tombstone["_sdc_surrogate_key"] != "abc" || tombstone["_sdc_deleted_at"] != "2024-01-01T00:00:00Z" {
+		t.Errorf("unexpected tombstone %v", tombstone)
+	}
+}
@@ -1,22 +1,18 @@
 package models
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 	"time"
 
 	util "github.com/5amCurfew/xtkt/util"
 )
 
-// Compile-time verification that Schema implements Model interface
-var _ Model = (*Schema)(nil)
-
 // Schema represents a JSON schema with generation and update capabilities.
 // It provides methods for working with JSON schemas including property
 // management, schema generation from records, and schema merging.
 // While similar to Model entities, Schema is an in-memory data structure
-// with parameterized methods for flexibility.
+// with parameterized methods for flexibility (Message takes the stream name).
 type Schema map[string]interface{}
 
 func (s *Schema) initEmpty() {
@@ -100,23 +96,19 @@ func (s *Schema) Merge(newRecord map[string]interface{}) error {
 	return nil
 }
 
-// Message generates a SCHEMA type message and writes it to stdout
-func (s *Schema) Message() error {
+// Message generates a SCHEMA type message for the stream and writes it to w
+func (s *Schema) Message(w io.Writer, stream string) error {
 	message := Message{
 		Type:          "SCHEMA",
-		Stream:        STREAM_NAME,
+		Stream:        stream,
 		Schema:        s.ToMap(),
 		KeyProperties: []string{"_sdc_unique_key", "_sdc_surrogate_key"},
 	}
 
-	messageJson, err := json.Marshal(message)
-	if err != nil {
+	if err := writeMessage(w, message); err != nil {
 		return fmt.Errorf("error creating schema message: %w", err)
 	}
 
-	os.Stdout.Write(messageJson)
-	os.Stdout.Write([]byte("\n"))
-
 	return nil
 }
 
@@ -217,6 +209,9 @@ func generateSchemaFromRecord(record interface{}) (map[string]interface{}, error
 			prop["type"] = []string{"array", "null"}
 		case nil:
 			continue
+		case util.Base64:
+			prop["type"] = []string{"string", "null"}
+			prop["contentEncoding"] = "base64"
 		case string:
 			if util.IsTimestampString(v) {
 				prop["type"] = []string{"string", "null"}
@@ -3,8 +3,10 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"sync"
+	"time"
 
 	util "github.com/5amCurfew/xtkt/util"
 )
@@ -20,21 +22,45 @@ type StreamState struct {
 	LastExtractionStartedAt string   `json:"last_extraction_started_at,omitempty"`
 	Bookmark                Bookmark `json:"bookmark"`
 	PreviousBookmark        Bookmark `json:"-"`
-}
+	ReplicationKeyPath      []string `json:"-"` // records.replication_key_path, whose maximum is tracked during a run
+	ReadOnly                bool     `json:"-"` // never write <stream_name>_state.json (e.g. with --confirmed-state)
 
-var State StreamState
-var bookmarkUpdates chan BookmarkUpdate
-var bookmarkUpdaterWG sync.WaitGroup
+	bookmarkUpdates   chan BookmarkUpdate
+	bookmarkUpdaterWG sync.WaitGroup
+	replicationKeyMax interface{} // maximum replication key value seen this run, owned by the bookmark writer
+}
 
 // Create creates a state JSON file for the stream
+// Expects a string parameter containing the stream name, optionally followed by a
+// *StreamState supplied by the caller (e.g. --state) which is used instead of the state file
 func (s *StreamState) Create(source ...interface{}) error {
+	if len(source) > 0 {
+		streamName, ok := source[0].(string)
+		if !ok {
+			return fmt.Errorf("stream name must be string, got %T", source[0])
+		}
+		s.Stream = streamName
+	}
+
+	if len(source) > 1 {
+		input, ok := source[1].(*StreamState)
+		if !ok {
+			return fmt.Errorf("input state must be *StreamState, got %T", source[1])
+		}
+		s.LastExtractionStartedAt = input.LastExtractionStartedAt
+		s.Bookmark = input.Bookmark.Clone()
+		if s.Bookmark.Latest == nil {
+			s.Bookmark.Latest = map[string]BookmarkEntry{}
+		}
+		return nil
+	}
+
 	// Check if file already exists
-	if _, err := os.Stat(fmt.Sprintf("%s_state.json", STREAM_NAME)); err == nil {
+	if _, err := os.Stat(fmt.Sprintf("%s_state.json", s.Stream)); err == nil && s.Stream != "" {
 		// File exists, read it instead of creating new
 		return s.Read()
 	}
 
-	s.Stream = STREAM_NAME
 	if s.Stream == "" {
 		return fmt.Errorf("error creating state file: stream name is required")
 	}
@@ -42,6 +68,9 @@ func (s *StreamState) Create(source ...interface{}) error {
 		UpdatedAt: util.NowTimestamp(),
 		Latest:    map[string]BookmarkEntry{},
 	}
+	if s.ReadOnly {
+		return nil
+	}
 
 	fileName := fmt.Sprintf("%s_state.json", s.Stream)
 	err := util.WriteJSON(fileName, s)
@@ -54,7 +83,7 @@ func (s *StreamState) Create(source ...interface{}) error {
 
 // Read reads the State JSON file
 func (s *StreamState) Read() error {
-	stateFile, err := os.ReadFile(fmt.Sprintf("%s_state.json", STREAM_NAME))
+	stateFile, err := os.ReadFile(fmt.Sprintf("%s_state.json", s.Stream))
 	if err != nil {
 		return fmt.Errorf("error reading state file: %w", err)
 	}
@@ -70,8 +99,11 @@ func (s *StreamState) Read() error {
 	return nil
 }
 
-// Update writes the current state to the JSON file
+// Update writes the current state to the JSON file, unless the state is ReadOnly
 func (s *StreamState) Update() error {
+	if s.ReadOnly {
+		return nil
+	}
 	fileName := fmt.Sprintf("%s_state.json", s.Stream)
 	err := util.WriteJSON(fileName, s)
 	if err != nil {
@@ -80,22 +112,18 @@ func (s *StreamState) Update() error {
 	return nil
 }
 
-// Message generates a message with the current state
-func (s *StreamState) Message() error {
+// Message generates a STATE message holding this stream's state in the Singer {"bookmarks": {...}} shape
+// Bookmark updates must be stopped (or the state taken from Snapshot) while the message is written
+func (s *StreamState) Message(w io.Writer) error {
 	message := Message{
-		Type:   "STATE",
-		Stream: s.Stream,
-		Value:  s.Bookmark,
+		Type:  "STATE",
+		Value: &TapState{Bookmarks: map[string]*StreamState{s.Stream: s}},
 	}
 
-	messageJson, err := json.Marshal(message)
-	if err != nil {
+	if err := writeMessage(w, message); err != nil {
 		return fmt.Errorf("error creating state message: %w", err)
 	}
 
-	os.Stdout.Write(messageJson)
-	os.Stdout.Write([]byte("\n"))
-
 	return nil
 }
 
@@ -103,11 +131,21 @@ func (s *StreamState) Message() error {
 func (s *StreamState) StartExtraction() {
 	s.LastExtractionStartedAt = util.NowTimestamp()
 	s.PreviousBookmark = s.Bookmark.Clone()
+	s.replicationKeyMax = nil
+}
+
+// AdvanceReplicationKey moves the replication key watermark on to the maximum value seen this run.
+// It must only be called once bookmark updates are stopped after a complete extraction, as records
+// may arrive out of order and a partial run would otherwise skip the records it never reached.
+func (s *StreamState) AdvanceReplicationKey() {
+	if replicationKeyAfter(s.replicationKeyMax, s.Bookmark.ReplicationKeyValue) {
+		s.Bookmark.ReplicationKeyValue = s.replicationKeyMax
+	}
 }
 
 // StartBookmarkUpdates starts the single-writer goroutine that owns bookmark mutations.
 func (s *StreamState) StartBookmarkUpdates() {
-	if bookmarkUpdates != nil {
+	if s.bookmarkUpdates != nil {
 		return
 	}
 
@@ -115,12 +153,17 @@ func (s *StreamState) StartBookmarkUpdates() {
 		s.Bookmark.Latest = map[string]BookmarkEntry{}
 	}
 
-	bookmarkUpdates = make(chan BookmarkUpdate, 1024)
-	bookmarkUpdaterWG.Add(1)
+	updates := make(chan BookmarkUpdate, 1024)
+	s.bookmarkUpdates = updates
+	s.bookmarkUpdaterWG.Add(1)
 
 	go func() {
-		defer bookmarkUpdaterWG.Done()
-		for update := range bookmarkUpdates {
+		defer s.bookmarkUpdaterWG.Done()
+		for update := range updates {
+			if update.snapshot != nil {
+				update.snapshot <- s.Bookmark.Clone()
+				continue
+			}
 			s.applyBookmarkUpdate(update)
 		}
 	}()
@@ -128,13 +171,33 @@ func (s *StreamState) StartBookmarkUpdates() {
 
 // StopBookmarkUpdates drains outstanding bookmark updates before final state persistence.
 func (s *StreamState) StopBookmarkUpdates() {
-	if bookmarkUpdates == nil {
+	if s.bookmarkUpdates == nil {
 		return
 	}
 
-	close(bookmarkUpdates)
-	bookmarkUpdaterWG.Wait()
-	bookmarkUpdates = nil
+	close(s.bookmarkUpdates)
+	s.bookmarkUpdaterWG.Wait()
+	s.bookmarkUpdates = nil
+}
+
+// Snapshot returns a copy of the state including every bookmark update queued before the call,
+// so a STATE message sent after a record never omits that record's bookmark
+func (s *StreamState) Snapshot() *StreamState {
+	snapshot := &StreamState{
+		Stream:                  s.Stream,
+		LastExtractionStartedAt: s.LastExtractionStartedAt,
+	}
+
+	if s.bookmarkUpdates == nil {
+		snapshot.Bookmark = s.Bookmark.Clone()
+		return snapshot
+	}
+
+	// Taken by the bookmark writer goroutine once it has applied every earlier update
+	bookmark := make(chan Bookmark)
+	s.bookmarkUpdates <- BookmarkUpdate{snapshot: bookmark}
+	snapshot.Bookmark = <-bookmark
+	return snapshot
 }
 
 // QueueBookmarkUpdate enqueues a bookmark mutation for the current extraction run.
@@ -145,13 +208,16 @@ func (s *StreamState) QueueBookmarkUpdate(record map[string]interface{}, emitted
 		Timestamp:    util.NowTimestamp(),
 		Emitted:      emitted,
 	}
+	if len(s.ReplicationKeyPath) > 0 {
+		update.ReplicationKeyValue = util.GetValueAtPath(s.ReplicationKeyPath, record)
+	}
 
-	if bookmarkUpdates == nil {
+	if s.bookmarkUpdates == nil {
 		s.applyBookmarkUpdate(update)
 		return
 	}
 
-	bookmarkUpdates <- update
+	s.bookmarkUpdates <- update
 }
 
 func (s *StreamState) applyBookmarkUpdate(update BookmarkUpdate) {
@@ -159,36 +225,135 @@ func (s *StreamState) applyBookmarkUpdate(update BookmarkUpdate) {
 		s.Bookmark.Latest = map[string]BookmarkEntry{}
 	}
 
-	// Convert natural key to string for bookmark storage (avoiding scientific notation)
-	key := util.ToKeyString(update.NaturalKey)
+	// Convert natural key to string for bookmark storage (avoiding scientific notation and composite key collisions)
+	key := util.NaturalKeyString(update.NaturalKey)
 	entry := s.Bookmark.Latest[key]
 	if update.Emitted {
 		entry.LastEmitted = update.Timestamp
 	}
 
 	entry.SurrogateKey = update.SurrogateKey
+	entry.HashVersion = SurrogateKeyVersion
 	entry.LastSeen = update.Timestamp
+	// A record seen again is no longer a deletion candidate
+	entry.MissedRuns = 0
+	entry.DeletedAt = ""
 	s.Bookmark.Latest[key] = entry
 	s.Bookmark.UpdatedAt = update.Timestamp
+
+	if replicationKeyAfter(update.ReplicationKeyValue, s.replicationKeyMax) {
+		s.replicationKeyMax = update.ReplicationKeyValue
+	}
 }
 
-// BookmarkEntry tracks the surrogate key and last seen timestamp for a record
+// replicationKeyAfter reports whether value is later than current, comparing numbers numerically,
+// RFC 3339 timestamps chronologically and other strings lexically. Values of different kinds never compare later.
+func replicationKeyAfter(value interface{}, current interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if current == nil {
+		return true
+	}
+
+	if number, ok := replicationKeyNumber(value); ok {
+		currentNumber, ok := replicationKeyNumber(current)
+		return ok && number > currentNumber
+	}
+
+	text, ok := value.(string)
+	currentText, currentOk := current.(string)
+	if !ok || !currentOk {
+		return false
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, text)
+	currentTimestamp, currentErr := time.Parse(time.RFC3339Nano, currentText)
+	if err == nil && currentErr == nil {
+		return timestamp.After(currentTimestamp)
+	}
+	return text > currentText
+}
+
+// replicationKeyNumber converts a numeric replication key value (from JSON or a database driver) to float64
+func replicationKeyNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// DetectDeletions increments the missed run count of every bookmark entry not seen since the
+// current extraction started, returning entries that have exceeded the grace period.
+// Returned entries are marked deleted so a tombstone is only emitted once per deletion.
+func (s *StreamState) DetectDeletions(gracePeriod int) (map[string]BookmarkEntry, error) {
+	startedAt, err := time.Parse(util.TimestampFormat, s.LastExtractionStartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing last_extraction_started_at: %w", err)
+	}
+
+	deletedAt := util.NowTimestamp()
+	deleted := map[string]BookmarkEntry{}
+	for key, entry := range s.Bookmark.Latest {
+		if entry.DeletedAt != "" {
+			continue
+		}
+
+		lastSeen, err := time.Parse(util.TimestampFormat, entry.LastSeen)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing last_seen for natural key %s: %w", key, err)
+		}
+		if !lastSeen.Before(startedAt) {
+			continue
+		}
+
+		entry.MissedRuns += 1
+		if entry.MissedRuns > gracePeriod {
+			entry.DeletedAt = deletedAt
+			deleted[key] = entry
+		}
+		s.Bookmark.Latest[key] = entry
+	}
+
+	if len(deleted) > 0 {
+		s.Bookmark.UpdatedAt = deletedAt
+	}
+
+	return deleted, nil
+}
+
+// BookmarkEntry tracks the surrogate key and last seen timestamp for a record.
+// HashVersion is the SurrogateKeyVersion of the surrogate key, absent for version 1.
 type BookmarkEntry struct {
 	SurrogateKey string `json:"surrogate_key"`
+	HashVersion  int    `json:"hash_version,omitempty"`
 	LastSeen     string `json:"last_seen"`
 	LastEmitted  string `json:"last_emitted,omitempty"`
+	MissedRuns   int    `json:"missed_runs,omitempty"`
+	DeletedAt    string `json:"deleted_at,omitempty"`
 }
 
 type BookmarkUpdate struct {
-	NaturalKey   interface{}
-	SurrogateKey string
-	Timestamp    string
-	Emitted      bool
+	NaturalKey          interface{}
+	SurrogateKey        string
+	Timestamp           string
+	Emitted             bool
+	ReplicationKeyValue interface{}
+
+	snapshot chan<- Bookmark // set for snapshot requests, which carry no update
 }
 
+// Bookmark holds the latest bookmark entry of every natural key and, for streams with a
+// records.replication_key_path, the replication key watermark reached by the last complete run.
 type Bookmark struct {
-	UpdatedAt string                   `json:"updated_at"`
-	Latest    map[string]BookmarkEntry `json:"latest"`
+	UpdatedAt           string                   `json:"updated_at"`
+	ReplicationKeyValue interface{}              `json:"replication_key_value,omitempty"`
+	Latest              map[string]BookmarkEntry `json:"latest"`
 }
 
 func (b Bookmark) Clone() Bookmark {
@@ -198,7 +363,8 @@ func (b Bookmark) Clone() Bookmark {
 	}
 
 	return Bookmark{
-		UpdatedAt: b.UpdatedAt,
-		Latest:    latest,
+		UpdatedAt:           b.UpdatedAt,
+		ReplicationKeyValue: b.ReplicationKeyValue,
+		Latest:              latest,
 	}
 }
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDetectDeletions(t *testing.T) {
+	tests := []struct {
+		name        string
+		gracePeriod int
+		runs        []string // natural keys seen in each run
+		want        []string // natural keys deleted by each run
+		missedRuns  map[string]int
+	}{
+		{
+			name: "deleted on the first missed run",
+			runs: []string{"a,b,c", "a,c", "a,c"},
+			want: []string{"", "b", ""},
+		},
+		{
+			name:        "deleted once the grace period is exceeded",
+			gracePeriod: 2,
+			runs:        []string{"a,b", "a", "a", "a", "a"},
+			want:        []string{"", "", "", "b", ""},
+			missedRuns:  map[string]int{"a": 0, "b": 3},
+		},
+		{
+			name:        "seen again within the grace period",
+			gracePeriod: 1,
+			runs:        []string{"a,b", "a", "a,b", "a", "a"},
+			want:        []string{"", "", "", "", "b"},
+			missedRuns:  map[string]int{"b": 2},
+		},
+		{
+			name: "deleted then restored",
+			runs: []string{"a,b", "a", "a,b", "a"},
+			want: []string{"", "b", "", "b"},
+		},
+		{
+			name: "every key missing",
+			runs: []string{"a,b", ""},
+			want: []string{"", "a,b"},
+		},
+	}
+
+	for _, test := range tests {
+		state := StreamState{Stream: "records", Bookmark: Bookmark{Latest: map[string]BookmarkEntry{}}}
+		for run, seen := range test.runs {
+			state.StartExtraction()
+			for _, key := range strings.Split(seen, ",") {
+				if key != "" {
+					state.QueueBookmarkUpdate(map[string]interface{}{"_sdc_natural_key": key, "_sdc_surrogate_key": "sk-" + key}, true)
+				}
+			}
+
+			deleted, err := state.DetectDeletions(test.gracePeriod)
+			if err != nil {
+				t.Fatalf("%s: run %d: unexpected error: %v", test.name, run+1, err)
+			}
+			keys := []string{}
+			for key, entry := range deleted {
+				keys = append(keys, key)
+				if entry.DeletedAt == "" || state.Bookmark.Latest[key].DeletedAt != entry.DeletedAt {
+					t.Errorf("%s: run %d: %s is not marked deleted", test.name, run+1, key)
+				}
+			}
+			sort.Strings(keys)
+			if got := strings.Join(keys, ","); got != test.want[run] {
+				t.Errorf("%s: run %d: deleted %q, want %q", test.name, run+1, got, test.want[run])
+			}
+		}
+
+		for key, want := range test.missedRuns {
+			if got := state.Bookmark.Latest[key].MissedRuns; got != want {
+				t.Errorf("%s: %s missed %d runs, want %d", test.name, key, got, want)
+			}
+		}
+	}
+}
+
+func TestDetectDeletionsInvalidTimestamps(t *testing.T) {
+	state := StreamState{LastExtractionStartedAt: "yesterday"}
+	if _, err := state.DetectDeletions(0); err == nil {
+		t.Error("accepted an invalid last_extraction_started_at")
+	}
+
+	state.StartExtraction()
+	state.Bookmark.Latest = map[string]BookmarkEntry{"a": {LastSeen: "yesterday"}}
+	if _, err := state.DetectDeletions(0); err == nil || !strings.Contains(err.Error(), "last_seen") {
+		t.Errorf("got error %v, want a last_seen error", err)
+	}
+}
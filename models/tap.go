@@ -0,0 +1,262 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Compile-time verification that TapCatalog and TapState implement Model interface
+var _ Model = (*TapCatalog)(nil)
+var _ Model = (*TapState)(nil)
+
+// TapCatalog represents the Singer catalog covering every stream of a run, in the
+// standard {"streams": [...]} shape read by --catalog and written by --discover.
+type TapCatalog struct {
+	Streams []TapCatalogStream `json:"streams"`
+}
+
+type TapCatalogStream struct {
+	TapStreamID   string               `json:"tap_stream_id"`
+	Stream        string               `json:"stream"`
+	Schema        Schema               `json:"schema"`
+	KeyProperties []string             `json:"key_properties,omitempty"`
+	Metadata      []TapCatalogMetadata `json:"metadata,omitempty"`
+}
+
+type TapCatalogMetadata struct {
+	Breadcrumb []string               `json:"breadcrumb"`
+	Metadata   map[string]interface{} `json:"metadata"`
+}
+
+// Create loads the TapCatalog from a JSON file, or builds it from discovered catalogs
+// Expects either a string file path or a []StreamCatalog
+func (c *TapCatalog) Create(source ...interface{}) error {
+	if len(source) == 0 {
+		return fmt.Errorf("catalog file path or stream catalogs required")
+	}
+
+	switch src := source[0].(type) {
+	case string:
+		catalogData, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("error reading catalog file: %w", err)
+		}
+		if err := json.Unmarshal(catalogData, c); err != nil {
+			return fmt.Errorf("error unmarshaling catalog json: %w", err)
+		}
+	case []StreamCatalog:
+		c.Streams = make([]TapCatalogStream, 0, len(src))
+		for _, catalog := range src {
+			c.Streams = append(c.Streams, TapCatalogStream{
+				TapStreamID:   catalog.Stream,
+				Stream:        catalog.Stream,
+				Schema:        catalog.Schema,
+				KeyProperties: catalog.KeyProperties,
+				Metadata: []TapCatalogMetadata{{
+					Breadcrumb: []string{},
+					Metadata: map[string]interface{}{
+						"inclusion":            "available",
+						"selected-by-default":  true,
+						"table-key-properties": catalog.KeyProperties,
+					},
+				}},
+			})
+		}
+	default:
+		return fmt.Errorf("catalog source must be string or []StreamCatalog, got %T", source[0])
+	}
+
+	return nil
+}
+
+// Read reads the catalog (JSON file is loaded via Create, so this is a no-op)
+func (c *TapCatalog) Read() error {
+	return nil
+}
+
+// Update updates the catalog (no-op; stream catalogs are persisted individually)
+func (c *TapCatalog) Update() error {
+	return nil
+}
+
+// Message writes the catalog as a single JSON document, as Singer runners expect from discovery
+func (c *TapCatalog) Message(w io.Writer) error {
+	catalogJson, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error creating catalog message: %w", err)
+	}
+
+	_, err = w.Write(append(catalogJson, '\n'))
+	return err
+}
+
+// Lookup returns the catalog for the named stream and whether it is selected.
+// Streams are selected unless their stream-level metadata sets "selected" to false.
+func (c *TapCatalog) Lookup(streamName string) (catalog *StreamCatalog, selected bool, found bool) {
+	for _, stream := range c.Streams {
+		if stream.Stream != streamName && stream.TapStreamID != streamName {
+			continue
+		}
+
+		selected = true
+		for _, metadata := range stream.Metadata {
+			if len(metadata.Breadcrumb) == 0 && metadata.Metadata["selected"] == false {
+				selected = false
+			}
+		}
+
+		keyProperties := stream.KeyProperties
+		if len(keyProperties) == 0 {
+			keyProperties = []string{"_sdc_unique_key", "_sdc_surrogate_key"}
+		}
+
+		return &StreamCatalog{
+			KeyProperties: keyProperties,
+			Schema:        stream.Schema,
+			Stream:        streamName,
+		}, selected, true
+	}
+
+	return nil, false, false
+}
+
+// TapState represents the Singer state blob covering every stream of a run, keyed by stream name.
+// It is read by --state and written in STATE messages, which targets echo once records are loaded.
+type TapState struct {
+	Bookmarks map[string]*StreamState `json:"bookmarks"`
+
+	mu sync.Mutex // serialises checkpoints from concurrent streams
+}
+
+// Create loads the TapState from a JSON file, or from state JSON already read (e.g. echoed by a target)
+// Expects a string file path or []byte holding {"bookmarks": {...}}, a whole {"type": "STATE", "value": ...}
+// message or a single stream's <stream_name>_state.json; any other shape is an error
+func (t *TapState) Create(source ...interface{}) error {
+	if len(source) == 0 {
+		return fmt.Errorf("state file path required")
+	}
+
+	var stateData []byte
+	switch src := source[0].(type) {
+	case string:
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("error reading state file: %w", err)
+		}
+		stateData = data
+	case []byte:
+		stateData = src
+	default:
+		return fmt.Errorf("state source must be string or []byte, got %T", source[0])
+	}
+
+	var shape map[string]json.RawMessage
+	if err := json.Unmarshal(stateData, &shape); err != nil {
+		return fmt.Errorf("error unmarshaling state json: %w", err)
+	}
+
+	// A STATE message echoed whole, rather than its value
+	if value, message := shape["value"]; message && string(shape["type"]) == `"STATE"` {
+		return t.Create([]byte(value))
+	}
+
+	_, tap := shape["bookmarks"]
+	_, single := shape["bookmark"]
+	if !tap && !single {
+		return fmt.Errorf("unrecognised state json: expected {\"bookmarks\": {...}}, a STATE message or a <stream_name>_state.json")
+	}
+
+	t.Bookmarks = map[string]*StreamState{}
+	if single {
+		var state StreamState
+		if err := json.Unmarshal(stateData, &state); err != nil {
+			return fmt.Errorf("error unmarshaling state json: %w", err)
+		}
+		t.Bookmarks[state.Stream] = &state
+		return nil
+	}
+
+	if err := json.Unmarshal(stateData, t); err != nil {
+		return fmt.Errorf("error unmarshaling state json: %w", err)
+	}
+	if t.Bookmarks == nil {
+		t.Bookmarks = map[string]*StreamState{}
+	}
+
+	// The stream name is the bookmarks key; states written by hand may omit it
+	for name, state := range t.Bookmarks {
+		if state == nil {
+			delete(t.Bookmarks, name)
+			continue
+		}
+		state.Stream = name
+	}
+
+	return nil
+}
+
+// Read reads the state (JSON file is loaded via Create, so this is a no-op)
+func (t *TapState) Read() error {
+	return nil
+}
+
+// Update writes each stream's state to its <stream_name>_state.json file
+func (t *TapState) Update() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, state := range t.Bookmarks {
+		if err := state.Update(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Message generates a STATE message holding the state of every stream
+func (t *TapState) Message(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.message(w)
+}
+
+// Checkpoint replaces a stream's state and writes a STATE message holding the state of every stream, as
+// runners keep only the latest STATE message. Each message holds every bookmark entry of every stream, so
+// its size grows with the records seen.
+// state must not be mutated afterwards; pass a StreamState.Snapshot while bookmarks are being updated.
+func (t *TapState) Checkpoint(w io.Writer, state *StreamState) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.Bookmarks == nil {
+		t.Bookmarks = map[string]*StreamState{}
+	}
+	t.Bookmarks[state.Stream] = state
+
+	return t.message(w)
+}
+
+func (t *TapState) message(w io.Writer) error {
+	message := Message{
+		Type:  "STATE",
+		Value: t,
+	}
+
+	if err := writeMessage(w, message); err != nil {
+		return fmt.Errorf("error creating state message: %w", err)
+	}
+
+	return nil
+}
+
+// Stream returns the state for the named stream, or an empty state if the blob has none
+func (t *TapState) Stream(streamName string) *StreamState {
+	if state, ok := t.Bookmarks[streamName]; ok && state != nil {
+		return state
+	}
+	return &StreamState{Stream: streamName}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTapStateCheckpointHoldsEveryStream(t *testing.T) {
+	tap := &TapState{}
+	var output bytes.Buffer
+
+	for _, stream := range []string{"orders", "customers", "orders"} {
+		state := &StreamState{Stream: stream, Bookmark: Bookmark{Latest: map[string]BookmarkEntry{"1": {SurrogateKey: stream}}}}
+		if err := tap.Checkpoint(&output, state); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	want := [][]string{{"orders"}, {"customers", "orders"}, {"customers", "orders"}}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d STATE messages, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		var message struct {
+			Type  string `json:"type"`
+			Value struct {
+				Bookmarks map[string]json.RawMessage `json:"bookmarks"`
+			} `json:"value"`
+		}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("message %d: invalid json: %v", i, err)
+		}
+
+		var streams []string
+		for stream := range message.Value.Bookmarks {
+			streams = append(streams, stream)
+		}
+		sort.Strings(streams)
+		if message.Type != "STATE" || strings.Join(streams, ",") != strings.Join(want[i], ",") {
+			t.Errorf("message %d: %s of %v, want STATE of %v", i, message.Type, streams, want[i])
+		}
+	}
+
+	// Each message is accepted by --state
+	var state TapState
+	if err := state.Create([]byte(lines[len(lines)-1])); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(state.Bookmarks) != 2 {
+		t.Errorf("got %d streams from the last message, want 2", len(state.Bookmarks))
+	}
+}
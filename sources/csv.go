@@ -1,25 +1,24 @@
 package sources
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"strings"
 
-	lib "github.com/5amCurfew/xtkt/lib"
 	"github.com/5amCurfew/xtkt/models"
 )
 
 // StreamCSVRecords streams records from a CSV file
-func StreamCSVRecords(config *models.StreamConfig) error {
+func StreamCSVRecords(ctx context.Context, config *models.StreamConfig, records chan<- map[string]interface{}) error {
 	url := config.URL
 
 	var reader *csv.Reader
 	switch {
 	case strings.HasPrefix(url, "http"):
-		response, err := http.Get(url)
+		response, err := httpGet(ctx, fileHTTPClient, url)
 		if err != nil {
 			return fmt.Errorf("http.Get failed: %w", err)
 		}
@@ -55,7 +54,9 @@ func StreamCSVRecords(config *models.StreamConfig) error {
 		for i, value := range row {
 			record[header[i]] = value
 		}
-		lib.ExtractedChan <- record
+		if err := emitRecord(ctx, records, record); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,194 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/5amCurfew/xtkt/models"
+	util "github.com/5amCurfew/xtkt/util"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const defaultDBBatchSize = 1000
+
+// StreamDBRecords streams records from a relational database table or query
+func StreamDBRecords(ctx context.Context, config *models.StreamConfig, records chan<- map[string]interface{}) error {
+	query := dbQuery(config.DB)
+
+	db, err := sql.Open(config.DB.Driver, config.DB.DSN)
+	if err != nil {
+		return fmt.Errorf("sql.Open failed: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database connection failed: %w", err)
+	}
+
+	batchSize := config.DB.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultDBBatchSize
+	}
+
+	// Postgres sends a plain query's full result set at once, so page through a server-side cursor.
+	// MySQL and SQLite drivers already stream rows from the connection as they are scanned.
+	if config.DB.Driver == "postgres" {
+		return streamPostgresCursor(ctx, db, query, batchSize, records)
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	_, err = streamRows(ctx, rows, records)
+	return err
+}
+
+// streamPostgresCursor declares a server-side cursor and fetches batchSize rows at a time
+func streamPostgresCursor(ctx context.Context, db *sql.DB, query string, batchSize int, records chan<- map[string]interface{}) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DECLARE xtkt_cursor NO SCROLL CURSOR FOR "+query); err != nil {
+		return fmt.Errorf("error declaring cursor: %w", err)
+	}
+
+	for {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf("FETCH FORWARD %d FROM xtkt_cursor", batchSize))
+		if err != nil {
+			return fmt.Errorf("error fetching from cursor: %w", err)
+		}
+
+		count, err := streamRows(ctx, rows, records)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if count < batchSize {
+			break
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "CLOSE xtkt_cursor"); err != nil {
+		return fmt.Errorf("error closing cursor: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// streamRows sends each row to the pipeline as a record keyed by column name, returning the row count
+func streamRows(ctx context.Context, rows *sql.Rows, records chan<- map[string]interface{}) (int, error) {
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, fmt.Errorf("error reading column types: %w", err)
+	}
+
+	count := 0
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			return count, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			record[column.Name()] = convertDBValue(values[i], column.DatabaseTypeName())
+		}
+
+		if err := emitRecord(ctx, records, record); err != nil {
+			return count, err
+		}
+		count += 1
+	}
+
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return count, nil
+}
+
+// convertDBValue maps a scanned column value onto a JSON type understood by schema discovery
+func convertDBValue(value interface{}, databaseType string) interface{} {
+	databaseType = strings.ToUpper(databaseType)
+
+	switch v := value.(type) {
+	case nil, bool, int64, float64:
+		return v
+	case time.Time:
+		if databaseType == "DATE" {
+			return v.Format("2006-01-02")
+		}
+		return util.FormatTimestamp(v)
+	case []byte:
+		// Binary columns (and bytes that are not text) would be corrupted as strings
+		if isBinaryDBType(databaseType) || !utf8.Valid(v) {
+			return util.Base64(base64.StdEncoding.EncodeToString(v))
+		}
+		return convertDBValue(string(v), databaseType)
+	case string:
+		// DECIMAL and NUMERIC stay strings, as a float64 would round money and values beyond 2^53
+		switch databaseType {
+		case "FLOAT", "DOUBLE", "REAL":
+			if number, err := strconv.ParseFloat(v, 64); err == nil {
+				return number
+			}
+		case "DATETIME", "TIMESTAMP":
+			if timestamp, err := time.Parse("2006-01-02 15:04:05.999999999", v); err == nil {
+				return util.FormatTimestamp(timestamp)
+			}
+		}
+		return v
+	default:
+		return util.ToString(v)
+	}
+}
+
+// isBinaryDBType reports whether a column's database type holds bytes rather than text
+func isBinaryDBType(databaseType string) bool {
+	switch databaseType {
+	case "BYTEA", "BIT", "GEOMETRY":
+		return true
+	}
+	return strings.Contains(databaseType, "BLOB") || strings.Contains(databaseType, "BINARY")
+}
+
+// dbQuery returns the configured SQL query, or a select of every column when only a table is configured
+func dbQuery(config models.DBConfig) string {
+	if config.Query != "" {
+		return strings.TrimRight(strings.TrimSpace(config.Query), ";")
+	}
+	return "SELECT * FROM " + quoteDBIdentifier(config.Driver, config.Table)
+}
+
+// quoteDBIdentifier quotes each part of a (optionally schema-qualified) table name for the driver
+func quoteDBIdentifier(driver string, identifier string) string {
+	quote := `"`
+	if driver == "mysql" {
+		quote = "`"
+	}
+
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		parts[i] = quote + strings.ReplaceAll(part, quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,52 @@
+package sources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/5amCurfew/xtkt/models"
+	util "github.com/5amCurfew/xtkt/util"
+)
+
+func TestConvertDBValue(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        interface{}
+		databaseType string
+		want         interface{}
+	}{
+		{"integer", int64(42), "INTEGER", int64(42)},
+		{"null", nil, "TEXT", nil},
+		{"decimal bytes", []byte("12345678901234567.89"), "NUMERIC", "12345678901234567.89"},
+		{"decimal string", "0.10", "decimal", "0.10"},
+		{"float", []byte("1.5"), "DOUBLE", 1.5},
+		{"date", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), "DATE", "2024-02-29"},
+		{"mysql datetime", []byte("2024-02-29 13:14:15"), "DATETIME", "2024-02-29T13:14:15Z"},
+		{"text", []byte("hello"), "VARCHAR", "hello"},
+		{"binary column", []byte("hello"), "BLOB", util.Base64("aGVsbG8=")},
+		{"invalid utf-8", []byte{0x00, 0xff}, "TEXT", util.Base64("AP8=")},
+	}
+
+	for _, test := range tests {
+		if got := convertDBValue(test.value, test.databaseType); got != test.want {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDBQuery(t *testing.T) {
+	tests := []struct {
+		driver, table, query, want string
+	}{
+		{"postgres", "public.users", "", `SELECT * FROM "public"."users"`},
+		{"mysql", "we`ird", "", "SELECT * FROM `we``ird`"},
+		{"sqlite", "", " SELECT id FROM users; ", "SELECT id FROM users"},
+	}
+
+	for _, test := range tests {
+		config := models.DBConfig{Driver: test.driver, DSN: "dsn", Table: test.table, Query: test.query}
+		if got := dbQuery(config); got != test.want {
+			t.Errorf("dbQuery(%+v) = %s, want %s", config, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/5amCurfew/xtkt/models"
+	util "github.com/5amCurfew/xtkt/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// StreamGraphQLRecords streams records from a GraphQL API, following Relay-style connection pagination
+func StreamGraphQLRecords(ctx context.Context, config *models.StreamConfig, records chan<- map[string]interface{}) error {
+	graphql := config.GraphQL
+
+	// Requests are sent by the REST client, on a copy of the config holding the GraphQL HTTP settings
+	requestConfig := *config
+	requestConfig.Rest = graphql.RESTConfig()
+	client := newRESTClient(&requestConfig)
+
+	variables := make(map[string]interface{}, len(graphql.Variables)+1)
+	for key, value := range graphql.Variables {
+		variables[key] = value
+	}
+
+	for {
+		log.WithFields(log.Fields{
+			"source_type": config.SourceType,
+			"url":         config.URL,
+			"variables":   variables,
+		}).Info("requesting source page")
+
+		body := map[string]interface{}{
+			"query":     graphql.Query,
+			"variables": variables,
+		}
+		response, _, err := client.getRequest(ctx, &requestConfig, body)
+		if err != nil {
+			return fmt.Errorf("getRequest failed: %w", err)
+		}
+
+		var responseMap map[string]interface{}
+		if err := json.Unmarshal(response, &responseMap); err != nil {
+			return fmt.Errorf("error json.Unmarshal into responseMap: %w", err)
+		}
+
+		// GraphQL reports query errors in a successful response
+		if queryErrors, ok := responseMap["errors"].([]interface{}); ok && len(queryErrors) > 0 {
+			message, _ := json.Marshal(queryErrors)
+			return fmt.Errorf("graphql errors: %s", message)
+		}
+
+		items, err := extractRecords(responseMap, graphql.RecordsPath)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			// Connection edges wrap each record in a node
+			if edge, ok := item.(map[string]interface{}); ok {
+				if node, ok := edge["node"].(map[string]interface{}); ok {
+					item = node
+				}
+			}
+
+			if recordMap, ok := item.(map[string]interface{}); ok {
+				if err := emitRecord(ctx, records, recordMap); err != nil {
+					return err
+				}
+			} else {
+				log.WithFields(log.Fields{
+					"item": item,
+					"url":  config.URL,
+				}).Warn("records array contained non-object item")
+			}
+		}
+
+		pageInfo, _ := util.GetValueAtPath(graphql.PageInfoPathOrDefault(), responseMap).(map[string]interface{})
+		if hasNextPage, _ := pageInfo["hasNextPage"].(bool); !hasNextPage {
+			return nil
+		}
+		endCursor := pageInfo["endCursor"]
+		if endCursor == nil || endCursor == "" {
+			return nil
+		}
+		variables[graphql.CursorVariableName()] = endCursor
+	}
+}
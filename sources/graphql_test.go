@@ -0,0 +1,112 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+func TestGraphQLPagination(t *testing.T) {
+	const total = 5
+	const pageSize = 2
+
+	tests := []struct {
+		name    string
+		graphql models.GraphQLConfig
+		edges   bool
+	}{
+		{"edges", models.GraphQLConfig{RecordsPath: []string{"data", "repository", "issues", "edges"}}, true},
+		{"nodes", models.GraphQLConfig{RecordsPath: []string{"data", "repository", "issues", "nodes"}}, false},
+		{
+			"page info path and cursor variable",
+			models.GraphQLConfig{RecordsPath: []string{"data", "repository", "issues", "nodes"}, PageInfoPath: []string{"data", "repository", "issues", "meta"}, CursorVariable: "cursor"},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				var body struct {
+					Query     string                 `json:"query"`
+					Variables map[string]interface{} `json:"variables"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Method != "POST" {
+					t.Errorf("got a %s request with error %v, want a JSON POST", r.Method, err)
+				}
+				if body.Query != "query" || body.Variables["owner"] != "octocat" {
+					t.Errorf("got query %q and variables %v", body.Query, body.Variables)
+				}
+
+				start := 0
+				if cursor, ok := body.Variables[test.graphql.CursorVariableName()].(string); ok {
+					start, _ = strconv.Atoi(strings.TrimPrefix(cursor, "c"))
+				}
+				items := []interface{}{}
+				for id := start; id < start+pageSize && id < total; id++ {
+					var item interface{} = map[string]interface{}{"id": id}
+					if test.edges {
+						item = map[string]interface{}{"cursor": fmt.Sprintf("c%d", id+1), "node": item}
+					}
+					items = append(items, item)
+				}
+
+				recordsKey := test.graphql.RecordsPath[len(test.graphql.RecordsPath)-1]
+				pageInfoKey := test.graphql.PageInfoPathOrDefault()[len(test.graphql.PageInfoPathOrDefault())-1]
+				writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"repository": map[string]interface{}{"issues": map[string]interface{}{
+					recordsKey:  items,
+					pageInfoKey: map[string]interface{}{"hasNextPage": start+pageSize < total, "endCursor": fmt.Sprintf("c%d", start+pageSize)},
+				}}}})
+			}))
+			defer server.Close()
+
+			config := &models.StreamConfig{StreamName: "issues", SourceType: "graphql", URL: server.URL, GraphQL: test.graphql}
+			config.GraphQL.Query = "query"
+			config.GraphQL.Variables = map[string]interface{}{"owner": "octocat"}
+
+			records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+				return StreamGraphQLRecords(context.Background(), config, records)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := fmt.Sprint(recordIDs(records)); got != "[0 1 2 3 4]" {
+				t.Errorf("got records %s, want [0 1 2 3 4]", got)
+			}
+			for _, record := range records {
+				if _, wrapped := record["node"]; wrapped {
+					t.Errorf("edge %v was not unwrapped to its node", record)
+				}
+			}
+			if requests != 3 {
+				t.Errorf("got %d requests, want 3", requests)
+			}
+		})
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"data": nil, "errors": []interface{}{map[string]interface{}{"message": "Field 'issuez' doesn't exist"}}})
+	}))
+	defer server.Close()
+
+	config := &models.StreamConfig{StreamName: "issues", SourceType: "graphql", URL: server.URL}
+	config.GraphQL = models.GraphQLConfig{Query: "query", RecordsPath: []string{"data", "issues", "nodes"}}
+
+	_, err := collectRecords(func(records chan<- map[string]interface{}) error {
+		return StreamGraphQLRecords(context.Background(), config, records)
+	})
+	if err == nil || !strings.Contains(err.Error(), "issuez") {
+		t.Errorf("got error %v, want the graphql errors", err)
+	}
+}
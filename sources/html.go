@@ -0,0 +1,251 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/5amCurfew/xtkt/models"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// htmlCell is a table cell after colspan and rowspan expansion
+type htmlCell struct {
+	value      string
+	colspanned bool // true when the cell was filled from a cell spanning columns to its left
+}
+
+// htmlRowspan is a cell still spanning down into following rows
+type htmlRowspan struct {
+	cell htmlCell
+	rows int
+}
+
+// StreamHTMLRecords streams records from a table within an HTML page
+func StreamHTMLRecords(ctx context.Context, config *models.StreamConfig, records chan<- map[string]interface{}) error {
+	pageURL := config.URL
+
+	var body io.Reader
+	switch {
+	case strings.HasPrefix(pageURL, "http"):
+		response, err := httpGet(ctx, htmlHTTPClient, pageURL)
+		if err != nil {
+			return fmt.Errorf("http.Get failed: %w", err)
+		}
+		defer response.Body.Close()
+		body = response.Body
+
+	default:
+		file, err := os.Open(pageURL)
+		if err != nil {
+			return fmt.Errorf("os.Open failed: %w", err)
+		}
+		defer file.Close()
+		body = file
+	}
+
+	document, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	selector := config.HTML.TableSelector
+	if selector == "" {
+		selector = "table"
+	}
+
+	tables := document.Find(selector)
+	if config.HTML.TableIndex < 0 || config.HTML.TableIndex >= tables.Length() {
+		return fmt.Errorf("html table index %d not found; selector %q matched %d tables", config.HTML.TableIndex, selector, tables.Length())
+	}
+
+	grid := tableGrid(tables.Eq(config.HTML.TableIndex), config.HTML, pageURL)
+
+	headerRows := config.HTML.HeaderRows
+	if headerRows <= 0 {
+		headerRows = 1
+	}
+	if len(grid) < headerRows {
+		return fmt.Errorf("html table has %d rows; expected at least %d header rows", len(grid), headerRows)
+	}
+
+	header := tableHeader(grid[:headerRows], config.HTML.HeaderSeparator)
+
+	// Stream records
+	for _, row := range grid[headerRows:] {
+		record := make(map[string]interface{})
+		empty := true
+		for i, key := range header {
+			var value interface{}
+			if i < len(row) && !(row[i].colspanned && config.HTML.Colspan == "first") {
+				value = row[i].value
+				empty = empty && row[i].value == ""
+			}
+			record[key] = value
+		}
+
+		if empty {
+			continue
+		}
+		if err := emitRecord(ctx, records, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tableGrid expands the rows of a table into a rectangular grid, repeating cells across colspan and rowspan
+func tableGrid(table *goquery.Selection, config models.HTMLConfig, pageURL string) [][]htmlCell {
+	var grid [][]htmlCell
+	pending := map[int]htmlRowspan{} // column index => cell spanning down from a previous row
+
+	// Only rows of this table (the parser always wraps rows in a section), so nested tables don't leak into the grid
+	rows := table.ChildrenFiltered("thead, tbody, tfoot").ChildrenFiltered("tr")
+
+	rows.Each(func(_ int, tr *goquery.Selection) {
+		var row []htmlCell
+		column := 0
+
+		fillPending := func() {
+			for {
+				span, ok := pending[column]
+				if !ok {
+					return
+				}
+				row = append(row, span.cell)
+				if span.rows <= 1 {
+					delete(pending, column)
+				} else {
+					span.rows -= 1
+					pending[column] = span
+				}
+				column += 1
+			}
+		}
+
+		tr.ChildrenFiltered("th, td").Each(func(_ int, td *goquery.Selection) {
+			fillPending()
+
+			value := cellValue(td, config, pageURL)
+			colspan := spanAttr(td, "colspan")
+			rowspan := spanAttr(td, "rowspan")
+
+			for i := 0; i < colspan; i++ {
+				cell := htmlCell{value: value, colspanned: i > 0}
+				row = append(row, cell)
+				if rowspan > 1 {
+					pending[column] = htmlRowspan{cell: cell, rows: rowspan - 1}
+				}
+				column += 1
+			}
+		})
+		fillPending()
+
+		grid = append(grid, row)
+	})
+
+	return grid
+}
+
+// tableHeader builds one key per column, joining multi-row header cells and de-duplicating names
+func tableHeader(headerRows [][]htmlCell, separator string) []string {
+	if separator == "" {
+		separator = " "
+	}
+
+	width := 0
+	for _, row := range headerRows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	header := make([]string, width)
+	seen := map[string]int{}
+	for i := range header {
+		var parts []string
+		for _, row := range headerRows {
+			if i >= len(row) || row[i].value == "" {
+				continue
+			}
+			// Cells spanning down from a previous header row are not repeated in the key
+			if len(parts) > 0 && parts[len(parts)-1] == row[i].value {
+				continue
+			}
+			parts = append(parts, row[i].value)
+		}
+
+		key := strings.Join(parts, separator)
+		if key == "" {
+			key = "column_" + strconv.Itoa(i+1)
+		}
+
+		seen[key] += 1
+		if seen[key] > 1 {
+			key = key + "_" + strconv.Itoa(seen[key])
+		}
+		header[i] = key
+	}
+
+	return header
+}
+
+// cellValue returns the whitespace-normalised text of a cell, or the href of its first anchor if configured
+func cellValue(td *goquery.Selection, config models.HTMLConfig, pageURL string) string {
+	if config.AnchorValue == "href" {
+		if href, ok := td.Find("a[href]").First().Attr("href"); ok {
+			return resolveHref(pageURL, href)
+		}
+	}
+	return strings.Join(strings.Fields(td.Text()), " ")
+}
+
+// resolveHref resolves a relative link against the page URL when the page was fetched over HTTP
+func resolveHref(pageURL string, href string) string {
+	if !strings.HasPrefix(pageURL, "http") {
+		return href
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// Spans beyond these limits are clamped to them, as browsers do, so one cell cannot allocate a huge grid
+const (
+	maxColspan = 1000
+	maxRowspan = 65534
+)
+
+// spanAttr reads a colspan or rowspan attribute, defaulting to 1 and clamped to maxColspan or maxRowspan
+func spanAttr(td *goquery.Selection, name string) int {
+	value, ok := td.Attr(name)
+	if !ok {
+		return 1
+	}
+
+	span, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || span < 1 {
+		return 1
+	}
+
+	limit := maxRowspan
+	if name == "colspan" {
+		limit = maxColspan
+	}
+	if span > limit {
+		return limit
+	}
+	return span
+}
@@ -0,0 +1,123 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+func TestHTMLTables(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		html  models.HTMLConfig
+		want  string
+	}{
+		{
+			name: "rowspan and colspan repeat cells",
+			table: `<tr><th>Region</th><th>Q1</th><th>Q2</th></tr>
+				<tr><td rowspan="2">North</td><td colspan="2">10</td></tr>
+				<tr><td>3</td><td>4</td></tr>
+				<tr><td>South</td><td>5</td><td>6</td></tr>`,
+			want: `[{"Q1":"10","Q2":"10","Region":"North"},{"Q1":"3","Q2":"4","Region":"North"},{"Q1":"5","Q2":"6","Region":"South"}]`,
+		},
+		{
+			name: "colspan first",
+			table: `<tr><th>Region</th><th>Q1</th><th>Q2</th></tr>
+				<tr><td rowspan="2">North</td><td colspan="2">10</td></tr>
+				<tr><td>3</td><td>4</td></tr>`,
+			html: models.HTMLConfig{Colspan: "first"},
+			want: `[{"Q1":"10","Q2":null,"Region":"North"},{"Q1":"3","Q2":"4","Region":"North"}]`,
+		},
+		{
+			name: "cells spanning rows and columns",
+			table: `<tr><th>A</th><th>B</th><th>C</th></tr>
+				<tr><td rowspan="2" colspan="2">x</td><td>1</td></tr>
+				<tr><td>2</td></tr>`,
+			want: `[{"A":"x","B":"x","C":"1"},{"A":"x","B":"x","C":"2"}]`,
+		},
+		{
+			name: "header rows joined",
+			table: `<tr><th rowspan="2">Name</th><th colspan="2">Score</th></tr>
+				<tr><th>Home</th><th>Away</th></tr>
+				<tr><td>Ann</td><td>1</td><td>2</td></tr>`,
+			html: models.HTMLConfig{HeaderRows: 2, HeaderSeparator: "_"},
+			want: `[{"Name":"Ann","Score_Away":"2","Score_Home":"1"}]`,
+		},
+		{
+			name: "duplicate and empty headers",
+			table: `<tr><th>A</th><th>A</th><th></th></tr>
+				<tr><td>1</td><td>2</td><td>3</td></tr>`,
+			want: `[{"A":"1","A_2":"2","column_3":"3"}]`,
+		},
+		{
+			name: "short and empty rows",
+			table: `<tr><th>A</th><th>B</th></tr>
+				<tr><td>1</td></tr>
+				<tr><td> </td><td></td></tr>`,
+			want: `[{"A":"1","B":null}]`,
+		},
+		{
+			name: "invalid spans",
+			table: `<tr><th>A</th><th>B</th></tr>
+				<tr><td colspan="0" rowspan="x">1</td><td>2</td></tr>`,
+			want: `[{"A":"1","B":"2"}]`,
+		},
+		{
+			name: "nested tables and links",
+			table: `<tr><th>Name</th><th>Page</th></tr>
+				<tr><td>Ann <table><tr><td>inner</td></tr></table></td><td><a href="/people/ann">profile</a></td></tr>`,
+			html: models.HTMLConfig{AnchorValue: "href"},
+			want: `[{"Name":"Ann inner","Page":"{{server}}/people/ann"}]`,
+		},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<html><body><table><tr><td>another table</td></tr></table><table class="data">` + test.table + `</table></body></html>`))
+		}))
+
+		config := &models.StreamConfig{StreamName: "table", SourceType: "html", URL: server.URL + "/tables", HTML: test.html}
+		config.HTML.TableSelector = "table.data"
+		records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+			return StreamHTMLRecords(context.Background(), config, records)
+		})
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		got, _ := json.Marshal(records)
+		if want := strings.ReplaceAll(test.want, "{{server}}", server.URL); string(got) != want {
+			t.Errorf("%s: got %s, want %s", test.name, got, want)
+		}
+	}
+}
+
+func TestHTMLTableIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<table><tr><th>A</th></tr><tr><td>first</td></tr></table><table><tr><th>A</th></tr><tr><td>second</td></tr></table>`))
+	}))
+	defer server.Close()
+
+	config := &models.StreamConfig{StreamName: "table", SourceType: "html", URL: server.URL, HTML: models.HTMLConfig{TableIndex: 1}}
+	records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+		return StreamHTMLRecords(context.Background(), config, records)
+	})
+	if err != nil || len(records) != 1 || records[0]["A"] != "second" {
+		t.Errorf("got records %v and error %v, want the second table", records, err)
+	}
+
+	config.HTML.TableIndex = 2
+	if _, err := collectRecords(func(records chan<- map[string]interface{}) error {
+		return StreamHTMLRecords(context.Background(), config, records)
+	}); err == nil || !strings.Contains(err.Error(), "matched 2 tables") {
+		t.Errorf("got error %v, want a table index error", err)
+	}
+}
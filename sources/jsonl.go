@@ -2,26 +2,25 @@ package sources
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"strings"
 
-	lib "github.com/5amCurfew/xtkt/lib"
 	"github.com/5amCurfew/xtkt/models"
 	log "github.com/sirupsen/logrus"
 )
 
 // StreamJSONLRecords streams records from a JSON Lines (JSONL) file
-func StreamJSONLRecords(config *models.StreamConfig) error {
+func StreamJSONLRecords(ctx context.Context, config *models.StreamConfig, records chan<- map[string]interface{}) error {
 	url := config.URL
 
 	var scanner *bufio.Scanner
 
 	switch {
 	case strings.HasPrefix(url, "http"):
-		response, err := http.Get(url)
+		response, err := httpGet(ctx, fileHTTPClient, url)
 		if err != nil {
 			return fmt.Errorf("http.Get failed: %w", err)
 		}
@@ -54,7 +53,9 @@ func StreamJSONLRecords(config *models.StreamConfig) error {
 			continue
 		}
 
-		lib.ExtractedChan <- record
+		if err := emitRecord(ctx, records, record); err != nil {
+			return err
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -0,0 +1,183 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/5amCurfew/xtkt/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// oauthRefreshMargin is how long before expiry an access token is proactively refreshed
+const oauthRefreshMargin = time.Minute
+
+// oauthSession caches the access token of a REST stream extraction, shared by concurrent page requests
+type oauthSession struct {
+	mu           sync.Mutex
+	accessToken  string
+	obtainedAt   time.Time
+	refreshAt    time.Time // zero when the token endpoint did not return expires_in
+	refreshToken string    // latest refresh token, as providers may rotate it on every refresh
+}
+
+// token returns a cached access token, requesting a new one if there is none or it is about to expire
+func (s *oauthSession) token(ctx context.Context, client *http.Client, config models.OAuthConfig) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && (s.refreshAt.IsZero() || time.Now().Before(s.refreshAt)) {
+		return s.accessToken, nil
+	}
+
+	refreshToken := s.refreshToken
+	if refreshToken == "" {
+		refreshToken = config.RefreshToken
+	}
+
+	response, err := getAccessToken(ctx, client, config, refreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	s.accessToken = response.AccessToken
+	s.obtainedAt = time.Now()
+	s.refreshAt = time.Time{}
+	if expiresIn := response.expiresIn(); expiresIn > 0 {
+		margin := oauthRefreshMargin
+		if expiresIn <= 2*margin {
+			margin = expiresIn / 2
+		}
+		s.refreshAt = s.obtainedAt.Add(expiresIn - margin)
+	}
+	if response.RefreshToken != "" {
+		s.refreshToken = response.RefreshToken
+	}
+
+	log.WithFields(log.Fields{
+		"grant_type": config.GrantTypeName(),
+		"expires_in": response.expiresIn().String(),
+	}).Info("obtained oauth access token")
+	return s.accessToken, nil
+}
+
+// invalidate discards the cached token if it is the one a rejected request sent, so the request re-authenticates
+// once, even when concurrent requests are rejected together or the token was only fetched for that request
+func (s *oauthSession) invalidate(sentToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken == sentToken {
+		s.accessToken = ""
+	}
+}
+
+// oauthTokenResponse is the token endpoint response of RFC 6749 section 5.1
+type oauthTokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	ExpiresIn    interface{} `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+}
+
+// expiresIn returns the token lifetime, which some providers send as a string
+func (r oauthTokenResponse) expiresIn() time.Duration {
+	var seconds float64
+	switch value := r.ExpiresIn.(type) {
+	case float64:
+		seconds = value
+	case string:
+		seconds, _ = strconv.ParseFloat(value, 64)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// getAccessToken requests an access token from the configured OAuth endpoint using the configured grant
+func getAccessToken(ctx context.Context, client *http.Client, config models.OAuthConfig, refreshToken string) (oauthTokenResponse, error) {
+	fields := url.Values{}
+	fields.Set("grant_type", config.GrantTypeName())
+	switch config.GrantTypeName() {
+	case "refresh_token":
+		fields.Set("refresh_token", refreshToken)
+	case "password":
+		fields.Set("username", config.Username)
+		fields.Set("password", config.Password)
+	}
+	if len(config.Scopes) > 0 {
+		fields.Set("scope", strings.Join(config.Scopes, " "))
+	}
+	if config.Audience != "" {
+		fields.Set("audience", config.Audience)
+	}
+	if config.ClientAuth != "basic" {
+		fields.Set("client_id", config.ClientID)
+		fields.Set("client_secret", config.ClientSecret)
+	}
+
+	payload, contentType, err := encodeTokenRequest(fields, config.Encoding)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.TokenURL, payload)
+	if err != nil {
+		return oauthTokenResponse{}, fmt.Errorf("error creating auth post request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	if config.ClientAuth == "basic" {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return oauthTokenResponse{}, fmt.Errorf("error auth post request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return oauthTokenResponse{}, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return oauthTokenResponse{}, fmt.Errorf("error obtaining access token: %w", &statusError{statusCode: resp.StatusCode, header: resp.Header, message: string(body)})
+	}
+
+	var response oauthTokenResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return oauthTokenResponse{}, fmt.Errorf("error json.Unmarshal of response: %w", err)
+	}
+	if response.AccessToken == "" {
+		return oauthTokenResponse{}, fmt.Errorf("access_token not found in response")
+	}
+	return response, nil
+}
+
+// encodeTokenRequest encodes the token request fields as application/x-www-form-urlencoded ("form")
+// or, by default, multipart/form-data
+func encodeTokenRequest(fields url.Values, encoding string) (io.Reader, string, error) {
+	if encoding == "form" {
+		return strings.NewReader(fields.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	payload := &bytes.Buffer{}
+	writer := multipart.NewWriter(payload)
+	for key := range fields {
+		if err := writer.WriteField(key, fields.Get(key)); err != nil {
+			return nil, "", fmt.Errorf("error writing form field: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("error writer.Close(): %w", err)
+	}
+	return payload, writer.FormDataContentType(), nil
+}
@@ -0,0 +1,118 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+func TestGetAccessToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		config models.OAuthConfig
+		want   map[string]string
+	}{
+		{
+			"refresh token",
+			models.OAuthConfig{ClientID: "id", ClientSecret: "secret"},
+			map[string]string{"grant_type": "refresh_token", "refresh_token": "stored", "client_id": "id", "client_secret": "secret"},
+		},
+		{
+			"client credentials as a form",
+			models.OAuthConfig{GrantType: "client_credentials", ClientID: "id", ClientSecret: "secret", Scopes: []string{"read", "write"}, Audience: "api", Encoding: "form"},
+			map[string]string{"grant_type": "client_credentials", "scope": "read write", "audience": "api", "client_id": "id", "refresh_token": ""},
+		},
+		{
+			"password with basic client auth",
+			models.OAuthConfig{GrantType: "password", ClientID: "id", ClientSecret: "secret", Username: "u", Password: "p", ClientAuth: "basic"},
+			map[string]string{"grant_type": "password", "username": "u", "password": "p", "client_id": "", "basic": "id:secret"},
+		},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for field, want := range test.want {
+				got := r.FormValue(field)
+				if field == "basic" {
+					username, password, _ := r.BasicAuth()
+					got = username + ":" + password
+				}
+				if got != want {
+					t.Errorf("%s: sent %s %q, want %q", test.name, field, got, want)
+				}
+			}
+			writeJSON(w, map[string]interface{}{"access_token": "token", "expires_in": "3600"})
+		}))
+
+		test.config.TokenURL = server.URL
+		response, err := getAccessToken(context.Background(), server.Client(), test.config, "stored")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		} else if response.AccessToken != "token" || response.expiresIn().Hours() != 1 {
+			t.Errorf("%s: got %+v", test.name, response)
+		}
+		server.Close()
+	}
+}
+
+func TestOAuthRefreshOnUnauthorised(t *testing.T) {
+	tests := []struct {
+		name          string
+		accepted      string // the access token the API accepts
+		tokenRequests int32
+		wantErr       bool
+	}{
+		{"cached token accepted", "token-1", 1, false},
+		{"revoked token refreshed", "token-2", 2, false},
+		{"re-authenticates only once", "never", 2, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tokenRequests atomic.Int32
+			var sentRefreshTokens []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/token" {
+					n := tokenRequests.Add(1)
+					sentRefreshTokens = append(sentRefreshTokens, r.FormValue("refresh_token"))
+					// The provider rotates the refresh token on every refresh
+					writeJSON(w, map[string]interface{}{"access_token": fmt.Sprintf("token-%d", n), "refresh_token": fmt.Sprintf("refresh-%d", n), "expires_in": 3600})
+					return
+				}
+				if r.Header.Get("Authorization") != "Bearer "+test.accepted {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				writeJSON(w, map[string]interface{}{"results": []interface{}{map[string]interface{}{"id": 1}}})
+			}))
+			defer server.Close()
+
+			config := restStream(server.URL + "/records")
+			config.Rest.Auth = models.AuthConfig{Required: true, Strategy: "oauth", OAuth: models.OAuthConfig{RefreshToken: "initial", TokenURL: server.URL + "/token"}}
+
+			records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+				return StreamRESTRecords(context.Background(), config, records)
+			})
+
+			var status *statusError
+			if test.wantErr && (!errors.As(err, &status) || status.statusCode != http.StatusUnauthorized) {
+				t.Errorf("got error %v, want a 401 error response", err)
+			}
+			if !test.wantErr && (err != nil || len(records) != 1) {
+				t.Errorf("got %d records and error %v, want 1 record", len(records), err)
+			}
+			if tokenRequests.Load() != test.tokenRequests {
+				t.Errorf("got %d token requests, want %d", tokenRequests.Load(), test.tokenRequests)
+			}
+			if len(sentRefreshTokens) > 1 && sentRefreshTokens[1] != "refresh-1" {
+				t.Errorf("refreshed with %q, want the rotated refresh-1", sentRefreshTokens[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+
+	"github.com/5amCurfew/xtkt/models"
+	util "github.com/5amCurfew/xtkt/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// streamChildRecords requests the child stream's URL for every record of its parent stream, with up to
+// rest.parent.parallel parents in flight. Records of different parents may arrive interleaved.
+// The parent stream is requested through the child's client, so both share its rate limit (and OAuth
+// session, when they authenticate alike).
+func streamChildRecords(ctx context.Context, client *restClient, config *models.StreamConfig, records chan<- map[string]interface{}) error {
+	parent := config.Rest.Parent
+	parallel := parent.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The parent stream is extracted on its own copy of its config, which pagination mutates
+	parentConfig := *parent.Config
+	parentClient := client
+	if !reflect.DeepEqual(parentConfig.Rest.Auth, config.Rest.Auth) {
+		parentClient = &restClient{http: client.http, limiter: client.limiter, oauth: &oauthSession{}}
+	}
+	parentRecords := make(chan map[string]interface{})
+	var parentErr error
+	go func() {
+		defer close(parentRecords)
+		parentErr = streamRESTStream(ctx, parentClient, &parentConfig, parentRecords)
+	}()
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	sem := make(chan struct{}, parallel)
+	for parentRecord := range parentRecords {
+		// Once cancelled, the parent records are only drained until the parent stream stops
+		if ctx.Err() != nil {
+			continue
+		}
+
+		keys, childURL, err := childRequest(config, parentRecord)
+		if err != nil && parent.SkipMissing {
+			log.WithFields(log.Fields{
+				"error":         err,
+				"parent_stream": parent.Stream,
+				"url":           config.URL,
+			}).Warn("parent record missing url variable; skipping")
+			continue
+		}
+		if err != nil {
+			fail(fmt.Errorf("parent stream %s record cannot be requested (set rest.parent.skip_missing to skip such records): %w", parent.Stream, err))
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		// Each parent paginates on its own copy of the child config
+		childConfig := *config
+		childConfig.URL = childURL
+		childConfig.Rest.Parent = models.ParentConfig{}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := streamChild(ctx, client, &childConfig, keys, records); err != nil {
+				fail(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if parentErr != nil {
+		return fmt.Errorf("parent stream %s failed: %w", parent.Stream, parentErr)
+	}
+	return ctx.Err()
+}
+
+// streamChild streams the records of a single parent's child URL, adding the parent keys to each as _sdc_parent
+func streamChild(ctx context.Context, client *restClient, config *models.StreamConfig, keys map[string]interface{}, records chan<- map[string]interface{}) error {
+	childRecords := make(chan map[string]interface{})
+	var childErr error
+	go func() {
+		defer close(childRecords)
+		childErr = streamRESTRecords(ctx, client, config, childRecords)
+	}()
+
+	var emitErr error
+	for record := range childRecords {
+		if emitErr != nil {
+			continue
+		}
+
+		// Every record gets its own copy, as transformations may modify it concurrently
+		parentKeys := make(map[string]interface{}, len(keys))
+		for key, value := range keys {
+			parentKeys[key] = value
+		}
+		record["_sdc_parent"] = parentKeys
+
+		emitErr = emitRecord(ctx, records, record)
+	}
+
+	if emitErr != nil {
+		return emitErr
+	}
+	if childErr != nil {
+		return fmt.Errorf("child request %s failed: %w", config.URL, childErr)
+	}
+	return nil
+}
+
+// childRequest reads the rest.parent.key_paths of a parent record, returning them with the child URL they render
+func childRequest(config *models.StreamConfig, parentRecord map[string]interface{}) (map[string]interface{}, string, error) {
+	keys := make(map[string]interface{}, len(config.Rest.Parent.KeyPaths))
+	for variable, path := range config.Rest.Parent.KeyPaths {
+		value := util.GetValueAtPath(path, parentRecord)
+		if value == nil || value == "" {
+			return nil, "", fmt.Errorf("no value at rest.parent.key_paths %v for {%s}", path, variable)
+		}
+		keys[variable] = value
+	}
+
+	childURL, err := models.RenderURL(config.URL, func(variable string) (string, error) {
+		return url.PathEscape(util.ToKeyString(keys[variable])), nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return keys, childURL, nil
+}
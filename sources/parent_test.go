@@ -0,0 +1,129 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+func TestStreamChildRecords(t *testing.T) {
+	var tokenRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			tokenRequests.Add(1)
+			writeJSON(w, map[string]interface{}{"access_token": "token", "expires_in": 3600})
+		case r.Header.Get("Authorization") != "Bearer token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/repos":
+			writeJSON(w, map[string]interface{}{"results": []interface{}{
+				map[string]interface{}{"name": "a"},
+				map[string]interface{}{"name": "b"},
+				map[string]interface{}{"id": "no name"},
+			}})
+		case strings.HasPrefix(r.URL.Path, "/repos/"):
+			name := strings.TrimPrefix(r.URL.Path, "/repos/")
+			writeJSON(w, map[string]interface{}{"results": []interface{}{map[string]interface{}{"sha": name + "1"}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	auth := models.AuthConfig{Required: true, Strategy: "oauth", OAuth: models.OAuthConfig{GrantType: "client_credentials", TokenURL: server.URL + "/token"}}
+	parent := models.StreamConfig{StreamName: "repos", SourceType: "rest", URL: server.URL + "/repos"}
+	parent.Rest.Auth = auth
+	parent.Rest.Response.RecordsPath = []string{"results"}
+
+	child := func(skipMissing bool) *models.StreamConfig {
+		config := models.StreamConfig{StreamName: "commits", SourceType: "rest", URL: server.URL + "/repos/{name}"}
+		config.Rest.Auth = auth
+		config.Rest.Response.RecordsPath = []string{"results"}
+		config.Rest.Parent = models.ParentConfig{Stream: "repos", KeyPaths: map[string][]string{"name": {"name"}}, Parallel: 2, SkipMissing: skipMissing, Config: &parent}
+		return &config
+	}
+
+	t.Run("missing key fails", func(t *testing.T) {
+		_, err := collectRecords(func(records chan<- map[string]interface{}) error {
+			return StreamRESTRecords(context.Background(), child(false), records)
+		})
+		if err == nil || !strings.Contains(err.Error(), "skip_missing") {
+			t.Errorf("got error %v, want a missing key error", err)
+		}
+	})
+
+	t.Run("missing key skipped", func(t *testing.T) {
+		tokenRequests.Store(0)
+		records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+			return StreamRESTRecords(context.Background(), child(true), records)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got []string
+		for _, record := range records {
+			got = append(got, record["sha"].(string)+"/"+record["_sdc_parent"].(map[string]interface{})["name"].(string))
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != "a1/a,b1/b" {
+			t.Errorf("got child records %v, want a1/a,b1/b", got)
+		}
+
+		// The parent and child requests share one client, and so one OAuth session
+		if tokenRequests.Load() != 1 {
+			t.Errorf("got %d token requests, want 1", tokenRequests.Load())
+		}
+	})
+}
+
+func TestChildRequest(t *testing.T) {
+	parentRecord := map[string]interface{}{
+		"id":    float64(1234567),
+		"name":  "hello world/v2",
+		"owner": map[string]interface{}{"login": "octocat"},
+		"empty": "",
+	}
+
+	tests := []struct {
+		url      string
+		keyPaths map[string][]string
+		want     string
+		wantErr  bool
+	}{
+		{"https://api.example.com/repos/{id}", map[string][]string{"id": {"id"}}, "https://api.example.com/repos/1234567", false},
+		{"https://api.example.com/repos/{owner}/{name}/commits", map[string][]string{"owner": {"owner", "login"}, "name": {"name"}}, "https://api.example.com/repos/octocat/hello%20world%2Fv2/commits", false},
+		{"https://api.example.com/repos/{id}?since={id}", map[string][]string{"id": {"id"}}, "https://api.example.com/repos/1234567?since=1234567", false},
+		{"https://api.example.com/repos/{missing}", map[string][]string{"missing": {"missing"}}, "", true},
+		{"https://api.example.com/repos/{empty}", map[string][]string{"empty": {"empty"}}, "", true},
+	}
+
+	for _, test := range tests {
+		config := &models.StreamConfig{URL: test.url}
+		config.Rest.Parent.KeyPaths = test.keyPaths
+
+		keys, got, err := childRequest(config, parentRecord)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: rendered %q, want an error", test.url, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.url, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: rendered %q, want %q", test.url, got, test.want)
+		}
+		if len(keys) != len(test.keyPaths) {
+			t.Errorf("%s: got parent keys %v", test.url, keys)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/5amCurfew/xtkt/models"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiter paces the requests of a REST stream to its configured rates and,
+// when adaptive, pauses until the API's advertised reset once its remaining quota reaches zero
+type rateLimiter struct {
+	config   models.RateLimitConfig
+	limiters []*rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func newRateLimiter(config models.RateLimitConfig) *rateLimiter {
+	burst := config.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+
+	limiter := &rateLimiter{config: config}
+	if config.RequestsPerSecond > 0 {
+		limiter.limiters = append(limiter.limiters, rate.NewLimiter(rate.Limit(config.RequestsPerSecond), burst))
+	}
+	if config.RequestsPerMinute > 0 {
+		limiter.limiters = append(limiter.limiters, rate.NewLimiter(rate.Limit(config.RequestsPerMinute/60), burst))
+	}
+	return limiter
+}
+
+// wait blocks until a request may be sent, or ctx is cancelled. A pause until a reset further away than
+// rate_limit.max_wait_ms fails instead, so the stream is not stalled for hours.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+
+	if limit := maxWait(l.config.MaxWaitMs, defaultMaxRateLimitWait); pause > limit {
+		return fmt.Errorf("%w: rate limit resets in %s, beyond rest.rate_limit.max_wait_ms (%s)", errWaitTooLong, pause.Round(time.Second), limit)
+	}
+
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	for _, limiter := range l.limiters {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observe reads the remaining quota and reset time from response headers,
+// pausing further requests until the reset once no requests remain
+func (l *rateLimiter) observe(header http.Header, url string) {
+	if !l.config.Adaptive {
+		return
+	}
+
+	remainingHeader := l.config.RemainingHeader
+	if remainingHeader == "" {
+		remainingHeader = "X-RateLimit-Remaining"
+	}
+	resetHeader := l.config.ResetHeader
+	if resetHeader == "" {
+		resetHeader = "X-RateLimit-Reset"
+	}
+
+	remaining, err := strconv.ParseFloat(header.Get(remainingHeader), 64)
+	if err != nil || remaining > 0 {
+		return
+	}
+
+	reset, ok := parseRateLimitReset(header.Get(resetHeader))
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if reset.After(l.pausedUntil) {
+		l.pausedUntil = reset
+		log.WithFields(log.Fields{
+			"reset": reset.UTC().Format(time.RFC3339),
+			"wait":  time.Until(reset).Round(time.Second).String(),
+			"url":   url,
+		}).Warn("rate limit quota exhausted; pausing requests until reset")
+	}
+}
+
+// parseRateLimitReset reads a reset header given as a Unix timestamp (e.g. GitHub)
+// or as seconds until the reset (e.g. the IETF RateLimit-Reset header)
+func parseRateLimitReset(value string) (time.Time, bool) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, false
+	}
+
+	// Values this large can only be timestamps; a delta would be over 30 years
+	if seconds > 1e9 {
+		return time.Unix(int64(seconds), 0), true
+	}
+	return time.Now().Add(time.Duration(seconds * float64(time.Second))), true
+}
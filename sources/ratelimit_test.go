@@ -0,0 +1,104 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+func TestParseRateLimitReset(t *testing.T) {
+	reset, ok := parseRateLimitReset("1700000000")
+	if !ok || !reset.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("got %s, %v for a Unix timestamp", reset, ok)
+	}
+
+	reset, ok = parseRateLimitReset("30")
+	if wait := time.Until(reset); !ok || wait < 29*time.Second || wait > 30*time.Second {
+		t.Errorf("got a wait of %s, %v for a delta of 30 seconds", wait, ok)
+	}
+
+	for _, value := range []string{"", "soon", "-1"} {
+		if _, ok := parseRateLimitReset(value); ok {
+			t.Errorf("parseRateLimitReset(%q) accepted an invalid reset", value)
+		}
+	}
+}
+
+func TestRateLimiterAdaptive(t *testing.T) {
+	exhausted := http.Header{}
+	exhausted.Set("X-RateLimit-Remaining", "0")
+	exhausted.Set("X-RateLimit-Reset", "0.2")
+
+	tests := []struct {
+		name    string
+		config  models.RateLimitConfig
+		header  http.Header
+		minWait time.Duration
+		wantErr error
+	}{
+		{"paused until the reset", models.RateLimitConfig{Adaptive: true}, exhausted, 150 * time.Millisecond, nil},
+		{"quota remaining", models.RateLimitConfig{Adaptive: true}, http.Header{"X-Ratelimit-Remaining": {"1"}, "X-Ratelimit-Reset": {"0.2"}}, 0, nil},
+		{"not adaptive", models.RateLimitConfig{}, exhausted, 0, nil},
+		{"custom headers", models.RateLimitConfig{Adaptive: true, RemainingHeader: "RateLimit-Remaining", ResetHeader: "RateLimit-Reset"}, http.Header{"Ratelimit-Remaining": {"0"}, "Ratelimit-Reset": {"0.2"}}, 150 * time.Millisecond, nil},
+		{"reset beyond max_wait_ms", models.RateLimitConfig{Adaptive: true, MaxWaitMs: 1000}, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"60"}}, 0, errWaitTooLong},
+		{"reset beyond the default max wait", models.RateLimitConfig{Adaptive: true}, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"7200"}}, 0, errWaitTooLong},
+	}
+
+	for _, test := range tests {
+		limiter := newRateLimiter(test.config)
+		limiter.observe(test.header, "https://api.example.com")
+
+		start := time.Now()
+		err := limiter.wait(context.Background())
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.wantErr)
+		}
+		if waited := time.Since(start); waited < test.minWait || (test.minWait == 0 && waited > 100*time.Millisecond) {
+			t.Errorf("%s: waited %s", test.name, waited)
+		}
+	}
+}
+
+func TestRateLimitedRequests(t *testing.T) {
+	const pages = 4
+	var sent []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, time.Now())
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		results := []interface{}{}
+		if page < pages {
+			results = append(results, map[string]interface{}{"id": page})
+		}
+		writeJSON(w, map[string]interface{}{"results": results})
+	}))
+	defer server.Close()
+
+	config := restStream(server.URL)
+	config.Rest.Response.Pagination = true
+	config.Rest.Response.PaginationStrategy = "query"
+	config.Rest.Response.PaginationQuery = models.PaginationQueryConfig{QueryParameter: "page", QueryValue: 1, QueryIncrement: 1}
+	config.Rest.RateLimit = models.RateLimitConfig{RequestsPerSecond: 20}
+
+	records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+		return StreamRESTRecords(context.Background(), config, records)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != pages {
+		t.Fatalf("got %d records, want %d", len(records), pages)
+	}
+
+	// 20 requests per second with a burst of 1 spaces requests 50ms apart
+	for i := 1; i < len(sent); i++ {
+		if gap := sent[i].Sub(sent[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("request %d sent %s after the last, want at least 50ms", i+1, gap)
+		}
+	}
+}
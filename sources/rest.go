@@ -2,76 +2,153 @@ package sources
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 
-	lib "github.com/5amCurfew/xtkt/lib"
 	"github.com/5amCurfew/xtkt/models"
 	util "github.com/5amCurfew/xtkt/util"
 	log "github.com/sirupsen/logrus"
 )
 
+// restPage is a fetched page of a REST stream, as seen by pagination
+type restPage struct {
+	header      http.Header
+	responseMap map[string]interface{}
+	records     []interface{}
+}
+
+// restClient performs the requests of a single REST stream extraction, shared by concurrent page requests
+type restClient struct {
+	http    *http.Client
+	limiter *rateLimiter
+	oauth   *oauthSession
+}
+
+func newRESTClient(config *models.StreamConfig) *restClient {
+	return &restClient{
+		http:    http.DefaultClient,
+		limiter: newRateLimiter(config.Rest.RateLimit),
+		oauth:   &oauthSession{},
+	}
+}
+
 // StreamRESTRecords streams records from a Rest-API
-func StreamRESTRecords(config *models.StreamConfig) error {
-	responseMapRecordsPath := []string{"results"}
+func StreamRESTRecords(ctx context.Context, config *models.StreamConfig, records chan<- map[string]interface{}) error {
+	return streamRESTStream(ctx, newRESTClient(config), config, records)
+}
 
-	for {
-		log.WithFields(log.Fields{
-			"source_type": config.SourceType,
-			"url":         config.URL,
-		}).Info("requesting source page")
-		response, err := getRequest()
-		if err != nil {
-			return fmt.Errorf("getRequest failed: %w", err)
+// streamRESTStream streams a stream's records with client, through its parent stream's records for a child stream
+func streamRESTStream(ctx context.Context, client *restClient, config *models.StreamConfig, records chan<- map[string]interface{}) error {
+	if config.Rest.Parent.Config != nil {
+		return streamChildRecords(ctx, client, config, records)
+	}
+	return streamRESTRecords(ctx, client, config, records)
+}
+
+// streamRESTRecords requests every page of the configured URL with client
+func streamRESTRecords(ctx context.Context, client *restClient, config *models.StreamConfig, records chan<- map[string]interface{}) error {
+	if err := setQueryParams(config, config.Rest.Request.Params); err != nil {
+		return err
+	}
+
+	// Pagination values sent in the body are added to a copy of the configured body
+	var body map[string]interface{}
+	if config.Rest.Request.Body != nil || config.Rest.Response.PaginatesInBody() {
+		body = cloneBody(config.Rest.Request.Body)
+	}
+
+	// Offset pagination sets the offset and limit of the first page as well as the following ones
+	if config.Rest.Response.Pagination && config.Rest.Response.PaginationStrategy == "offset" {
+		if err := setOffset(config, body, config.Rest.Response.PaginationOffset.Offset); err != nil {
+			return err
 		}
+	}
 
-		normalised, err := normaliseResponse(response, *config)
+	for {
+		page, err := fetchPage(ctx, client, config, body)
 		if err != nil {
 			return err
 		}
 
-		if config.Rest.Response.RecordsPath != nil {
-			responseMapRecordsPath = config.Rest.Response.RecordsPath
+		if err := emitPage(ctx, config, page, records); err != nil {
+			return err
 		}
 
-		var responseMap map[string]interface{}
-		if err := json.Unmarshal(normalised, &responseMap); err != nil {
-			return fmt.Errorf("error json.Unmarshal into responseMap: %w", err)
+		if !config.Rest.Response.Pagination {
+			break
 		}
 
-		records, err := extractRecords(responseMap, responseMapRecordsPath)
-		if err != nil {
-			return err
+		if config.Rest.Response.PaginationStrategy == "offset" && config.Rest.Response.PaginationOffset.Parallel > 1 {
+			return streamOffsetPagesParallel(ctx, client, config, body, page, records)
 		}
 
-		for _, item := range records {
-			if recordMap, ok := item.(map[string]interface{}); ok {
-				lib.ExtractedChan <- recordMap
-			} else {
-				log.WithFields(log.Fields{
-					"item": item,
-					"url":  config.URL,
-				}).Warn("records array contained non-object item")
+		if err := handlePagination(config, page, body); err != nil {
+			if err == errNoMorePages {
+				return nil
 			}
+			return err
 		}
+	}
 
-		if config.Rest.Response.Pagination {
-			if err := handlePagination(config, responseMap, records); err != nil {
-				if err == errNoMorePages {
-					return nil
-				}
+	return nil
+}
+
+// fetchPage requests the configured URL and extracts the page's records
+func fetchPage(ctx context.Context, client *restClient, config *models.StreamConfig, body map[string]interface{}) (restPage, error) {
+	log.WithFields(log.Fields{
+		"source_type": config.SourceType,
+		"url":         config.URL,
+	}).Info("requesting source page")
+	response, header, err := client.getRequest(ctx, config, body)
+	if err != nil {
+		return restPage{}, fmt.Errorf("getRequest failed: %w", err)
+	}
+
+	normalised, err := normaliseResponse(response, *config)
+	if err != nil {
+		return restPage{}, err
+	}
+
+	responseMapRecordsPath := []string{"results"}
+	if config.Rest.Response.RecordsPath != nil {
+		responseMapRecordsPath = config.Rest.Response.RecordsPath
+	}
+
+	var responseMap map[string]interface{}
+	if err := json.Unmarshal(normalised, &responseMap); err != nil {
+		return restPage{}, fmt.Errorf("error json.Unmarshal into responseMap: %w", err)
+	}
+
+	items, err := extractRecords(responseMap, responseMapRecordsPath)
+	if err != nil {
+		return restPage{}, err
+	}
+
+	return restPage{header: header, responseMap: responseMap, records: items}, nil
+}
+
+// emitPage sends each object in the page's records array to the pipeline
+func emitPage(ctx context.Context, config *models.StreamConfig, page restPage, records chan<- map[string]interface{}) error {
+	for _, item := range page.records {
+		if recordMap, ok := item.(map[string]interface{}); ok {
+			if err := emitRecord(ctx, records, recordMap); err != nil {
 				return err
 			}
 		} else {
-			break
+			log.WithFields(log.Fields{
+				"item": item,
+				"url":  config.URL,
+			}).Warn("records array contained non-object item")
 		}
 	}
-
 	return nil
 }
 
@@ -95,8 +172,21 @@ func normaliseResponse(response []byte, config models.StreamConfig) ([]byte, err
 	}
 }
 
-// extractRecords extracts the records from the response map at the specified path
+// extractRecords extracts the records from the response map at the specified path.
+// Paths using "*" or "**" gather every array they match (and every object, as one record) into one page.
 func extractRecords(responseMap map[string]interface{}, path []string) ([]interface{}, error) {
+	if util.PathMatchesMany(path) {
+		var records []interface{}
+		for _, value := range util.GetValuesAtPath(path, responseMap) {
+			if array, ok := value.([]interface{}); ok {
+				records = append(records, array...)
+			} else {
+				records = append(records, value)
+			}
+		}
+		return records, nil
+	}
+
 	records, ok := util.GetValueAtPath(path, responseMap).([]interface{})
 	if !ok {
 		return nil, fmt.Errorf("error: response map does not contain records array at path: %v", path)
@@ -106,123 +196,446 @@ func extractRecords(responseMap map[string]interface{}, path []string) ([]interf
 
 var errNoMorePages = fmt.Errorf("no more pages")
 
-func handlePagination(config *models.StreamConfig, responseMap map[string]interface{}, records []interface{}) error {
+// handlePagination prepares config (and body, for cursors sent in the body) for the next page request,
+// returning errNoMorePages once the last page has been read
+func handlePagination(config *models.StreamConfig, page restPage, body map[string]interface{}) error {
+	responseMap, records := page.responseMap, page.records
+
 	switch config.Rest.Response.PaginationStrategy {
+	case "cursor":
+		cursorConfig := config.Rest.Response.PaginationCursor
+		if len(cursorConfig.HasMorePath) > 0 {
+			if hasMore, _ := util.GetValueAtPath(cursorConfig.HasMorePath, responseMap).(bool); !hasMore {
+				return errNoMorePages
+			}
+		}
+
+		var cursor interface{}
+		if len(cursorConfig.CursorRecordPath) > 0 {
+			if len(records) == 0 {
+				return errNoMorePages
+			}
+			lastRecord, _ := records[len(records)-1].(map[string]interface{})
+			cursor = util.GetValueAtPath(cursorConfig.CursorRecordPath, lastRecord)
+		} else {
+			cursor = util.GetValueAtPath(cursorConfig.CursorPath, responseMap)
+		}
+		if cursor == nil || cursor == "" {
+			return errNoMorePages
+		}
+
+		if cursorConfig.Location == "body" {
+			body[cursorConfig.Parameter] = cursor
+			return nil
+		}
+
+		if err := setQueryParams(config, map[string]string{cursorConfig.Parameter: util.ToKeyString(cursor)}); err != nil {
+			return err
+		}
+	case "offset":
+		offsetConfig := config.Rest.Response.PaginationOffset
+		total, found, err := offsetTotal(config, responseMap)
+		if err != nil {
+			// A short last page may omit the total
+			if len(records) < offsetConfig.Limit {
+				return errNoMorePages
+			}
+			return err
+		}
+
+		// The offset advances by the records received, as servers may cap a page below the limit requested.
+		// With a total, pages are read until the offset reaches it; without one, a short page is the last.
+		nextOffset := offsetConfig.Offset + len(records)
+		switch {
+		case found && nextOffset >= total:
+			return errNoMorePages
+		case found && len(records) == 0:
+			log.WithFields(log.Fields{"offset": offsetConfig.Offset, "total": total, "url": config.URL}).Warn("empty page before the total was reached; stopping pagination")
+			return errNoMorePages
+		case !found && len(records) < offsetConfig.Limit:
+			return errNoMorePages
+		}
+
+		if err := setOffset(config, body, nextOffset); err != nil {
+			return err
+		}
+		config.Rest.Response.PaginationOffset.Offset = nextOffset
+	case "link_header":
+		nextURL := linkHeaderURL(page.header, "next")
+		if nextURL == "" {
+			return errNoMorePages
+		}
+		resolved, err := resolveURL(config.URL, nextURL)
+		if err != nil {
+			return err
+		}
+		config.URL = resolved
 	case "next":
-		nextURL := util.GetValueAtPath(config.Rest.Response.PaginationNextPath, responseMap)
-		if nextURL == nil || nextURL == "" {
+		// Paths using "*" or "**" follow the first URL they match
+		var nextURL string
+		for _, value := range util.GetValuesAtPath(config.Rest.Response.PaginationNextPath, responseMap) {
+			if nextURL, _ = value.(string); nextURL != "" {
+				break
+			}
+		}
+		if nextURL == "" {
 			return errNoMorePages
 		}
-		config.URL = nextURL.(string)
+		config.URL = nextURL
 	case "query":
 		if len(records) == 0 {
 			return errNoMorePages
 		}
-		parsedURL, err := url.Parse(config.URL)
-		if err != nil {
-			return fmt.Errorf("failed to parse URL: %w", err)
+		queryConfig := config.Rest.Response.PaginationQuery
+		if queryConfig.Location == "body" {
+			body[queryConfig.QueryParameter] = queryConfig.QueryValue
+		} else if err := setQueryParams(config, map[string]string{queryConfig.QueryParameter: strconv.Itoa(queryConfig.QueryValue)}); err != nil {
+			return err
 		}
-		query := parsedURL.Query()
-		query.Set(config.Rest.Response.PaginationQuery.QueryParameter, strconv.Itoa(config.Rest.Response.PaginationQuery.QueryValue))
-		parsedURL.RawQuery = query.Encode()
-		config.URL = parsedURL.String()
-		config.Rest.Response.PaginationQuery.QueryValue += config.Rest.Response.PaginationQuery.QueryIncrement
+		config.Rest.Response.PaginationQuery.QueryValue += queryConfig.QueryIncrement
 	}
 	return nil
 }
 
-// getRequest performs a GET request to the configured URL and handles authentication if required
-func getRequest() ([]byte, error) {
-	client := http.DefaultClient
+// setOffset sets the offset and limit of the next request, in the query string of the configured URL or in body
+func setOffset(config *models.StreamConfig, body map[string]interface{}, offset int) error {
+	offsetConfig := config.Rest.Response.PaginationOffset
+
+	if offsetConfig.Location == "body" {
+		body[offsetConfig.OffsetParameterName()] = offset
+		body[offsetConfig.LimitParameterName()] = offsetConfig.Limit
+		return nil
+	}
+
+	return setQueryParams(config, map[string]string{
+		offsetConfig.OffsetParameterName(): strconv.Itoa(offset),
+		offsetConfig.LimitParameterName():  strconv.Itoa(offsetConfig.Limit),
+	})
+}
+
+// setQueryParams sets query parameters of the configured URL, replacing any existing values
+func setQueryParams(config *models.StreamConfig, params map[string]string) error {
+	if len(params) == 0 {
+		return nil
+	}
 
-	req, err := http.NewRequest("GET", models.Config.URL, nil)
+	parsedURL, err := url.Parse(config.URL)
 	if err != nil {
-		return nil, fmt.Errorf("error creating get request: %w", err)
+		return fmt.Errorf("failed to parse URL: %w", err)
+	}
+	query := parsedURL.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	parsedURL.RawQuery = query.Encode()
+	config.URL = parsedURL.String()
+	return nil
+}
+
+// cloneBody copies the top level of a request body, which pagination writes to
+func cloneBody(body map[string]interface{}) map[string]interface{} {
+	clone := make(map[string]interface{}, len(body))
+	for key, value := range body {
+		clone[key] = value
+	}
+	return clone
+}
+
+// offsetTotal reads the total record count from the response, if a total path is configured
+func offsetTotal(config *models.StreamConfig, responseMap map[string]interface{}) (int, bool, error) {
+	totalPath := config.Rest.Response.PaginationOffset.TotalPath
+	if len(totalPath) == 0 {
+		return 0, false, nil
 	}
 
-	if models.Config.Rest.Auth.Required {
-		if err := setAuthHeaders(req, client); err != nil {
-			return nil, err
+	switch total := util.GetValueAtPath(totalPath, responseMap).(type) {
+	case float64:
+		return int(total), true, nil
+	case string:
+		parsed, err := strconv.Atoi(total)
+		if err != nil {
+			return 0, false, fmt.Errorf("error parsing total at path %v: %w", totalPath, err)
 		}
+		return parsed, true, nil
+	default:
+		return 0, false, fmt.Errorf("error: response map does not contain a total at path: %v", totalPath)
 	}
+}
 
-	resp, err := client.Do(req)
+// streamOffsetPagesParallel fetches every page after first concurrently once the total is known,
+// with up to pagination_offset.parallel requests in flight, each as long as the first page.
+// Records may arrive out of page order.
+func streamOffsetPagesParallel(ctx context.Context, client *restClient, config *models.StreamConfig, body map[string]interface{}, first restPage, records chan<- map[string]interface{}) error {
+	offsetConfig := config.Rest.Response.PaginationOffset
+	total, _, err := offsetTotal(config, first.responseMap)
 	if err != nil {
-		return nil, fmt.Errorf("error executing request: %w", err)
+		if len(first.records) < offsetConfig.Limit {
+			return nil
+		}
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		statusMsg, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("error response: %d %s", resp.StatusCode, string(statusMsg))
+	// Pages are as long as the first, as servers may cap a page below the limit requested
+	step := len(first.records)
+	if step == 0 || offsetConfig.Offset+step >= total {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	sem := make(chan struct{}, offsetConfig.Parallel)
+	for offset := offsetConfig.Offset + step; offset < total; offset += step {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		// Each request runs on its own copy of the config and body, which pagination mutates
+		pageConfig := *config
+		var pageBody map[string]interface{}
+		if body != nil {
+			pageBody = cloneBody(body)
+		}
+		if err := setOffset(&pageConfig, pageBody, offset); err != nil {
+			<-sem
+			fail(err)
+			break
+		}
+
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			page, err := fetchPage(ctx, client, &pageConfig, pageBody)
+			if expected := total - offset; err == nil && len(page.records) < step && len(page.records) < expected {
+				log.WithFields(log.Fields{"offset": offset, "records": len(page.records), "url": pageConfig.URL}).Warn("short page before the total was reached; records may have been removed at source")
+			}
+			if err == nil {
+				err = emitPage(ctx, &pageConfig, page, records)
+			}
+			if err != nil {
+				fail(err)
+			}
+		}(offset)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
 	}
-	return io.ReadAll(resp.Body)
+	return ctx.Err()
 }
 
-// setAuthHeaders sets the appropriate authentication headers based on the configured strategy
-func setAuthHeaders(req *http.Request, client *http.Client) error {
-	switch models.Config.Rest.Auth.Strategy {
-	case "basic":
-		req.SetBasicAuth(models.Config.Rest.Auth.Basic.Username, models.Config.Rest.Auth.Basic.Password)
-	case "token":
-		req.Header.Add(models.Config.Rest.Auth.Token.Header, models.Config.Rest.Auth.Token.HeaderValue)
-	case "oauth":
-		accessToken, _ := getAccessToken(client, models.Config)
-		header := "Authorization"
-		t := "Bearer " + accessToken
+// linkHeaderURL returns the target of the RFC 8288 Link header entry with the given rel, or "" if there is none
+// e.g. Link: <https://api.github.com/repositories/1/commits?page=2>; rel="next", <...?page=9>; rel="last"
+func linkHeaderURL(header http.Header, rel string) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range parseLinkHeader(value) {
+			// rel may hold several space-separated relation types
+			for _, relType := range strings.Fields(link.params["rel"]) {
+				if strings.EqualFold(relType, rel) {
+					return link.target
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// headerLink is one entry of a Link header: its target and parameters (names lower-cased, values unquoted)
+type headerLink struct {
+	target string
+	params map[string]string
+}
 
-		if models.Config.Rest.Auth.Token.Header == "" && models.Config.Rest.Auth.Token.HeaderValue == "" {
-			models.Config.Rest.Auth.Token = models.TokenAuthConfig{
-				Header:      header,
-				HeaderValue: t,
+// parseLinkHeader splits a Link header into its entries. Entries are only separated by commas outside
+// the <...> target and quoted parameter values, as both may contain commas (e.g. in a query string).
+func parseLinkHeader(value string) []headerLink {
+	var links []headerLink
+	i := 0
+	for {
+		// Skip to the next target, past separators and anything malformed
+		start := strings.IndexByte(value[i:], '<')
+		if start < 0 {
+			return links
+		}
+		i += start + 1
+		end := strings.IndexByte(value[i:], '>')
+		if end < 0 {
+			return links
+		}
+		link := headerLink{target: value[i : i+end], params: map[string]string{}}
+		i += end + 1
+
+		// Parameters follow as ; name="value" or ; name=token, up to the comma ending the entry
+		for i < len(value) && value[i] != ',' {
+			if value[i] != ';' {
+				i++
+				continue
+			}
+			i++
+
+			nameEnd := i
+			for nameEnd < len(value) && !strings.ContainsRune("=;,", rune(value[nameEnd])) {
+				nameEnd++
+			}
+			name := strings.ToLower(strings.TrimSpace(value[i:nameEnd]))
+			i = nameEnd
+			if i >= len(value) || value[i] != '=' {
+				link.params[name] = ""
+				continue
+			}
+			i++
+			for i < len(value) && (value[i] == ' ' || value[i] == '\t') {
+				i++
+			}
+
+			var paramValue strings.Builder
+			if i < len(value) && value[i] == '"' {
+				for i++; i < len(value) && value[i] != '"'; i++ {
+					if value[i] == '\\' && i+1 < len(value) {
+						i++
+					}
+					paramValue.WriteByte(value[i])
+				}
+				i++ // closing quote
+			} else {
+				for ; i < len(value) && value[i] != ';' && value[i] != ','; i++ {
+					paramValue.WriteByte(value[i])
+				}
+			}
+			// Only the first occurrence of a parameter is used, as RFC 8288 requires for rel
+			if _, seen := link.params[name]; !seen {
+				link.params[name] = strings.TrimSpace(paramValue.String())
 			}
 		}
+		links = append(links, link)
+	}
+}
 
-		models.Config.Rest.Auth.Strategy = "token"
-		return setAuthHeaders(req, client)
+// resolveURL resolves a (possibly relative) link against the URL of the page it was returned for
+func resolveURL(pageURL string, link string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL: %w", err)
 	}
-	return nil
+	ref, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse link URL: %w", err)
+	}
+	return base.ResolveReference(ref).String(), nil
 }
 
-// getAccessToken gets an access token from the configured OAuth endpoint
-func getAccessToken(client *http.Client, config models.StreamConfig) (string, error) {
-	const grantType = "refresh_token"
+// getRequest performs the configured request (by default a GET, or a POST when a JSON body is given)
+// and handles authentication if required, returning the response body and headers.
+// Failed requests are retried according to the rest.retry config.
+// A request rejected with 401 while using an OAuth access token re-authenticates once.
+func (c *restClient) getRequest(ctx context.Context, config *models.StreamConfig, body map[string]interface{}) ([]byte, http.Header, error) {
+	request := func() ([]byte, http.Header, error) {
+		return c.doRequest(ctx, config, body)
+	}
+
+	response, header, err := withRetry(ctx, config, request)
 
-	payload := &bytes.Buffer{}
-	writer := multipart.NewWriter(payload)
-	writer.WriteField("client_id", config.Rest.Auth.OAuth.ClientID)
-	writer.WriteField("client_secret", config.Rest.Auth.OAuth.ClientSecret)
-	writer.WriteField("grant_type", grantType)
-	writer.WriteField("refresh_token", config.Rest.Auth.OAuth.RefreshToken)
-	if err := writer.Close(); err != nil {
-		return "", fmt.Errorf("error writer.Close(): %w", err)
+	var status *statusError
+	if err != nil && config.Rest.Auth.Required && config.Rest.Auth.Strategy == "oauth" && errors.As(err, &status) && status.statusCode == http.StatusUnauthorized {
+		log.WithField("url", config.URL).Warn("request unauthorised; re-authenticating")
+		c.oauth.invalidate(status.accessToken)
+		return withRetry(ctx, config, request)
 	}
 
-	req, err := http.NewRequest("POST", config.Rest.Auth.OAuth.TokenURL, payload)
+	return response, header, err
+}
+
+// doRequest performs a single attempt of getRequest once the rate limit allows it
+func (c *restClient) doRequest(ctx context.Context, config *models.StreamConfig, body map[string]interface{}) ([]byte, http.Header, error) {
+	method := config.Rest.Request.MethodName(body != nil)
+	var requestBody io.Reader
+	if body != nil {
+		bodyJson, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error marshaling request body: %w", err)
+		}
+		requestBody = bytes.NewReader(bodyJson)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, config.URL, requestBody)
 	if err != nil {
-		return "", fmt.Errorf("error creating auth post request: %w", err)
+		return nil, nil, fmt.Errorf("error creating %s request: %w", method, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for header, value := range config.Rest.Request.Headers {
+		req.Header.Set(header, value)
+	}
+
+	var accessToken string
+	if config.Rest.Auth.Required {
+		if accessToken, err = c.setAuthHeaders(req, config); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, nil, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := client.Do(req)
+	resp, err := c.http.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error auth post request: %w", err)
+		return nil, nil, fmt.Errorf("error executing request: %w", err)
 	}
 	defer resp.Body.Close()
+	c.limiter.observe(resp.Header, config.URL)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading response body: %w", err)
+	if resp.StatusCode >= 400 {
+		statusMsg, _ := io.ReadAll(resp.Body)
+		return nil, nil, &statusError{statusCode: resp.StatusCode, header: resp.Header, message: string(statusMsg), accessToken: accessToken}
 	}
 
-	var responseMap map[string]interface{}
-	if err := json.Unmarshal(body, &responseMap); err != nil {
-		return "", fmt.Errorf("error json.Unmarshal of response: %w", err)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response body: %w", err)
 	}
+	return responseBody, resp.Header, nil
+}
 
-	token, ok := responseMap["access_token"].(string)
-	if !ok {
-		return "", fmt.Errorf("access_token not found in response")
+// setAuthHeaders sets the appropriate authentication headers based on the configured strategy,
+// returning the OAuth access token sent, if any
+func (c *restClient) setAuthHeaders(req *http.Request, config *models.StreamConfig) (string, error) {
+	switch config.Rest.Auth.Strategy {
+	case "basic":
+		req.SetBasicAuth(config.Rest.Auth.Basic.Username, config.Rest.Auth.Basic.Password)
+	case "token":
+		req.Header.Add(config.Rest.Auth.Token.Header, config.Rest.Auth.Token.HeaderValue)
+	case "oauth":
+		accessToken, err := c.oauth.token(req.Context(), c.http, config.Rest.Auth.OAuth)
+		if err != nil {
+			return "", err
+		}
+
+		// The access token is sent as a bearer token, in the Authorization header unless token.header names another
+		header := config.Rest.Auth.Token.Header
+		if header == "" {
+			header = "Authorization"
+		}
+		req.Header.Set(header, "Bearer "+accessToken)
+		return accessToken, nil
 	}
-	return token, nil
+	return "", nil
 }
@@ -0,0 +1,279 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+// collectRecords runs a source to completion, returning every record it emitted
+func collectRecords(stream func(records chan<- map[string]interface{}) error) ([]map[string]interface{}, error) {
+	records := make(chan map[string]interface{})
+	var err error
+	go func() {
+		defer close(records)
+		err = stream(records)
+	}()
+
+	var collected []map[string]interface{}
+	for record := range records {
+		collected = append(collected, record)
+	}
+	return collected, err
+}
+
+// writeJSON writes value as a JSON response
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// restStream returns the config of a REST stream of url reading records from "results"
+func restStream(url string) *models.StreamConfig {
+	config := &models.StreamConfig{StreamName: "records", SourceType: "rest", URL: url}
+	config.Rest.Response.RecordsPath = []string{"results"}
+	return config
+}
+
+// recordIDs returns the sorted "id" of every record
+func recordIDs(records []map[string]interface{}) []int {
+	ids := make([]int, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, int(record["id"].(float64)))
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func TestOffsetPagination(t *testing.T) {
+	const total = 25
+
+	tests := []struct {
+		name      string
+		pageCap   int // most records the server returns per page, whatever the limit requested
+		limit     int
+		totalPath []string
+		parallel  int
+		want      int
+	}{
+		{"total", 0, 10, []string{"total"}, 0, total},
+		{"total with the limit capped", 10, 20, []string{"total"}, 0, total},
+		{"parallel with the limit capped", 10, 20, []string{"total"}, 3, total},
+		{"parallel", 0, 7, []string{"total"}, 3, total},
+		{"short page without a total", 0, 10, nil, 0, total},
+		{"capped without a total stops at the short page", 10, 20, nil, 0, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+				if test.pageCap > 0 && limit > test.pageCap {
+					limit = test.pageCap
+				}
+
+				results := []interface{}{}
+				for id := offset; id < offset+limit && id < total; id++ {
+					results = append(results, map[string]interface{}{"id": id})
+				}
+				writeJSON(w, map[string]interface{}{"results": results, "total": total})
+			}))
+			defer server.Close()
+
+			config := restStream(server.URL)
+			config.Rest.Response.Pagination = true
+			config.Rest.Response.PaginationStrategy = "offset"
+			config.Rest.Response.PaginationOffset = models.PaginationOffsetConfig{Limit: test.limit, TotalPath: test.totalPath, Parallel: test.parallel}
+
+			records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+				return StreamRESTRecords(context.Background(), config, records)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			ids := recordIDs(records)
+			if len(ids) != test.want {
+				t.Fatalf("got %d records, want %d", len(ids), test.want)
+			}
+			for i, id := range ids {
+				if id != i {
+					t.Fatalf("got records %v, want every id from 0 once", ids)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		rel   string
+		want  string
+	}{
+		{"next", `<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=9>; rel="last"`, "next", "https://api.example.com/items?page=2"},
+		{"last", `<https://api.example.com/items?page=2>; rel="next", <https://api.example.com/items?page=9>; rel="last"`, "last", "https://api.example.com/items?page=9"},
+		{"unquoted rel", `<https://api.example.com/items?page=2>; rel=next`, "next", "https://api.example.com/items?page=2"},
+		{"comma in the target", `<https://api.example.com/items?ids=1,2&page=2>; rel="next"`, "next", "https://api.example.com/items?ids=1,2&page=2"},
+		{"comma in a quoted parameter", `<https://api.example.com/a>; title="a, b"; rel="prev", <https://api.example.com/b>; rel="next"`, "next", "https://api.example.com/b"},
+		{"several relation types", `<https://api.example.com/b>; rel="next last"`, "last", "https://api.example.com/b"},
+		{"rel is case-insensitive", `<https://api.example.com/b>; REL="Next"`, "next", "https://api.example.com/b"},
+		{"first rel wins", `<https://api.example.com/b>; rel="prev"; rel="next"`, "next", ""},
+		{"escaped quote", `<https://api.example.com/b>; title="say \"hi\""; rel="next"`, "next", "https://api.example.com/b"},
+		{"relative target", `</items?page=2>; rel="next"`, "next", "/items?page=2"},
+		{"no next", `<https://api.example.com/items?page=1>; rel="prev"`, "next", ""},
+		{"malformed", `https://api.example.com/items?page=2; rel="next"`, "next", ""},
+		{"empty", ``, "next", ""},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		header.Set("Link", test.value)
+		if got := linkHeaderURL(header, test.rel); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		pageURL, link, want string
+	}{
+		{"https://api.example.com/v1/items?page=1", "https://other.example.com/items?page=2", "https://other.example.com/items?page=2"},
+		{"https://api.example.com/v1/items?page=1", "/v2/items?page=2", "https://api.example.com/v2/items?page=2"},
+		{"https://api.example.com/v1/items?page=1", "items?page=2", "https://api.example.com/v1/items?page=2"},
+		{"https://api.example.com/v1/items?page=1", "?page=2", "https://api.example.com/v1/items?page=2"},
+		{"https://api.example.com/v1/items", "//cdn.example.com/items", "https://cdn.example.com/items"},
+	}
+
+	for _, test := range tests {
+		got, err := resolveURL(test.pageURL, test.link)
+		if err != nil {
+			t.Errorf("resolveURL(%q, %q): unexpected error: %v", test.pageURL, test.link, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("resolveURL(%q, %q) = %q, want %q", test.pageURL, test.link, got, test.want)
+		}
+	}
+
+	if _, err := resolveURL("https://api.example.com", "http://[::1"); err == nil {
+		t.Error("resolveURL accepted an invalid link")
+	}
+}
+
+func TestLinkHeaderPagination(t *testing.T) {
+	const pages = 3
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		// Relative links are resolved against the page they were returned for
+		if page < pages {
+			w.Header().Set("Link", fmt.Sprintf(`<https://elsewhere.example.com/items?page=%d>; rel="prev", <?page=%d>; rel="next"`, page-1, page+1))
+		}
+		writeJSON(w, map[string]interface{}{"results": []interface{}{
+			map[string]interface{}{"id": 2 * (page - 1)},
+			map[string]interface{}{"id": 2*(page-1) + 1},
+		}})
+	}))
+	defer server.Close()
+
+	config := restStream(server.URL + "/items")
+	config.Rest.Response.Pagination = true
+	config.Rest.Response.PaginationStrategy = "link_header"
+
+	records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+		return StreamRESTRecords(context.Background(), config, records)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(recordIDs(records)); got != "[0 1 2 3 4 5]" {
+		t.Errorf("got records %s, want [0 1 2 3 4 5]", got)
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	const total = 7
+	const pageSize = 3
+
+	tests := []struct {
+		name   string
+		cursor models.PaginationCursorConfig
+		body   bool
+	}{
+		{"cursor in the response", models.PaginationCursorConfig{CursorPath: []string{"meta", "next_cursor"}, Parameter: "cursor"}, false},
+		{"cursor from the last record", models.PaginationCursorConfig{CursorRecordPath: []string{"id"}, Parameter: "starting_after", HasMorePath: []string{"has_more"}}, false},
+		{"cursor in the body", models.PaginationCursorConfig{CursorPath: []string{"meta", "next_cursor"}, Parameter: "cursor", Location: "body"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				// Cursors are opaque: the server encodes the next id, the client sends it back as given
+				var cursor string
+				if test.body {
+					var body map[string]interface{}
+					json.NewDecoder(r.Body).Decode(&body)
+					cursor, _ = body[test.cursor.Parameter].(string)
+				} else {
+					cursor = r.URL.Query().Get(test.cursor.Parameter)
+				}
+				start := 0
+				if len(test.cursor.CursorRecordPath) > 0 && cursor != "" {
+					last, _ := strconv.Atoi(cursor)
+					start = last + 1
+				} else if cursor != "" {
+					start, _ = strconv.Atoi(strings.TrimPrefix(cursor, "c"))
+				}
+
+				results := []interface{}{}
+				for id := start; id < start+pageSize && id < total; id++ {
+					results = append(results, map[string]interface{}{"id": id})
+				}
+				next := ""
+				if start+pageSize < total {
+					next = fmt.Sprintf("c%d", start+pageSize)
+				}
+				writeJSON(w, map[string]interface{}{"results": results, "has_more": next != "", "meta": map[string]interface{}{"next_cursor": next}})
+			}))
+			defer server.Close()
+
+			config := restStream(server.URL)
+			config.Rest.Response.Pagination = true
+			config.Rest.Response.PaginationStrategy = "cursor"
+			config.Rest.Response.PaginationCursor = test.cursor
+			if test.body {
+				config.Rest.Request = models.RequestConfig{Method: "POST", Body: map[string]interface{}{"query": "all"}}
+			}
+
+			records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+				return StreamRESTRecords(context.Background(), config, records)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := fmt.Sprint(recordIDs(records)); got != "[0 1 2 3 4 5 6]" {
+				t.Errorf("got records %s, want [0 1 2 3 4 5 6]", got)
+			}
+			if requests != 3 {
+				t.Errorf("got %d requests, want 3", requests)
+			}
+		})
+	}
+}
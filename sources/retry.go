@@ -0,0 +1,155 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/5amCurfew/xtkt/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// errWaitTooLong is returned when an API asks for a longer wait than configured (rest.retry.max_retry_after_ms
+// or rest.rate_limit.max_wait_ms); it is never retried, so the stream fails rather than stalling
+var errWaitTooLong = errors.New("requested wait too long")
+
+// Defaults of rest.retry.max_retry_after_ms and rest.rate_limit.max_wait_ms
+const (
+	defaultMaxRetryAfter    = 5 * time.Minute
+	defaultMaxRateLimitWait = time.Hour
+)
+
+// statusError is an error status code returned by a REST API
+type statusError struct {
+	statusCode  int
+	header      http.Header
+	message     string
+	accessToken string // the OAuth access token the rejected request sent, if any
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("error response: %d %s", e.statusCode, e.message)
+}
+
+// withRetry runs request until it succeeds, fails with an error that is not retryable,
+// or has been attempted rest.retry.max_attempts times, backing off between attempts
+func withRetry(ctx context.Context, config *models.StreamConfig, request func() ([]byte, http.Header, error)) ([]byte, http.Header, error) {
+	retry := config.Rest.Retry
+
+	for attempt := 1; ; attempt++ {
+		body, header, err := request()
+		if err == nil {
+			return body, header, nil
+		}
+		if attempt >= retry.MaxAttempts || ctx.Err() != nil || !retryable(retry, err) {
+			return nil, nil, err
+		}
+
+		wait := backoff(retry, attempt)
+		var status *statusError
+		if errors.As(err, &status) {
+			if retryAfter, ok := parseRetryAfter(status.header.Get("Retry-After")); ok {
+				if maxRetryAfter := maxWait(retry.MaxRetryAfterMs, defaultMaxRetryAfter); retryAfter > maxRetryAfter {
+					return nil, nil, fmt.Errorf("%w: Retry-After of %s is beyond rest.retry.max_retry_after_ms (%s): %w", errWaitTooLong, retryAfter.Round(time.Second), maxRetryAfter, err)
+				}
+				wait = retryAfter
+			}
+		}
+
+		log.WithFields(log.Fields{
+			"attempt":      attempt,
+			"max_attempts": retry.MaxAttempts,
+			"wait":         wait.String(),
+			"error":        err,
+			"url":          config.URL,
+		}).Warn("request failed; retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, err
+		}
+	}
+}
+
+// retryable reports whether a failed request should be retried: transport errors always are,
+// error responses when their status code is retryable (by default 429 and 5xx)
+func retryable(retry models.RetryConfig, err error) bool {
+	if errors.Is(err, errWaitTooLong) {
+		return false
+	}
+
+	var status *statusError
+	if !errors.As(err, &status) {
+		return true
+	}
+
+	if len(retry.RetryStatusCodes) == 0 {
+		return status.statusCode == http.StatusTooManyRequests || status.statusCode >= 500
+	}
+	for _, code := range retry.RetryStatusCodes {
+		if status.statusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the exponential backoff before the attempt after the given one, capped at max_backoff_ms.
+// jitter randomly shortens the wait by up to that fraction so concurrent streams don't retry in lockstep.
+func backoff(retry models.RetryConfig, attempt int) time.Duration {
+	base := time.Duration(retry.BaseBackoffMs) * time.Millisecond
+	if base <= 0 {
+		base = time.Second
+	}
+	maxBackoff := time.Duration(retry.MaxBackoffMs) * time.Millisecond
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	wait := time.Duration(float64(base) * math.Pow(2, float64(attempt-1)))
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+
+	if retry.Jitter > 0 {
+		wait -= time.Duration(rand.Float64() * retry.Jitter * float64(wait))
+	}
+	return wait
+}
+
+// maxWait returns a configured maximum wait in milliseconds, or defaultWait when it is not set
+func maxWait(configuredMs int, defaultWait time.Duration) time.Duration {
+	if configuredMs <= 0 {
+		return defaultWait
+	}
+	return time.Duration(configuredMs) * time.Millisecond
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
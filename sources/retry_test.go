@@ -0,0 +1,119 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseRetryAfter(test.value)
+		if got != test.want || ok != test.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", test.value, got, ok, test.want, test.ok)
+		}
+	}
+
+	// An HTTP date is read as the wait until then
+	wait, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if !ok || wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("got %s, %v for a date an hour away", wait, ok)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	retry := models.RetryConfig{BaseBackoffMs: 100, MaxBackoffMs: 1000}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := backoff(retry, attempt+1); got != want*time.Millisecond {
+			t.Errorf("attempt %d: got %s, want %s", attempt+1, got, want*time.Millisecond)
+		}
+	}
+
+	retry.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := backoff(retry, 1); got <= 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("got %s with jitter 0.5, want within (50ms, 100ms]", got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		failures   int32 // responses failing before one succeeds
+		retry      models.RetryConfig
+		requests   int32
+		wantErr    error
+	}{
+		// The backoff is far longer than the test's deadline, so only Retry-After can make it pass
+		{"Retry-After is honoured", http.StatusTooManyRequests, "0", 2, models.RetryConfig{MaxAttempts: 3, BaseBackoffMs: 60000}, 3, nil},
+		{"5xx is retried", http.StatusServiceUnavailable, "", 1, models.RetryConfig{MaxAttempts: 3, BaseBackoffMs: 1}, 2, nil},
+		{"attempts run out", http.StatusBadGateway, "", 5, models.RetryConfig{MaxAttempts: 2, BaseBackoffMs: 1}, 2, &statusError{}},
+		{"4xx is not retried", http.StatusNotFound, "", 1, models.RetryConfig{MaxAttempts: 3, BaseBackoffMs: 1}, 1, &statusError{}},
+		{"configured codes", http.StatusConflict, "", 1, models.RetryConfig{MaxAttempts: 3, BaseBackoffMs: 1, RetryStatusCodes: []int{409}}, 2, nil},
+		{"Retry-After beyond the cap fails", http.StatusTooManyRequests, "120", 1, models.RetryConfig{MaxAttempts: 3, MaxRetryAfterMs: 1000}, 1, errWaitTooLong},
+		{"Retry-After beyond the default cap fails", http.StatusTooManyRequests, "3600", 1, models.RetryConfig{MaxAttempts: 3}, 1, errWaitTooLong},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= test.failures {
+					if test.retryAfter != "" {
+						w.Header().Set("Retry-After", test.retryAfter)
+					}
+					w.WriteHeader(test.status)
+					return
+				}
+				writeJSON(w, map[string]interface{}{"results": []interface{}{map[string]interface{}{"id": 1}}})
+			}))
+			defer server.Close()
+
+			config := restStream(server.URL)
+			config.Rest.Retry = test.retry
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			records, err := collectRecords(func(records chan<- map[string]interface{}) error {
+				return StreamRESTRecords(ctx, config, records)
+			})
+
+			var status *statusError
+			switch {
+			case test.wantErr == nil && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.wantErr == nil && len(records) != 1:
+				t.Errorf("got %d records, want 1", len(records))
+			case errors.As(test.wantErr, &status) && !errors.As(err, &status):
+				t.Errorf("got error %v, want an error response", err)
+			case errors.Is(test.wantErr, errWaitTooLong) && !errors.Is(err, errWaitTooLong):
+				t.Errorf("got error %v, want %v", err, errWaitTooLong)
+			}
+			if requests.Load() != test.requests {
+				t.Errorf("got %d requests, want %d", requests.Load(), test.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// emitRecord sends a record to the pipeline, returning early if the extraction is cancelled
+func emitRecord(ctx context.Context, records chan<- map[string]interface{}, record map[string]interface{}) error {
+	select {
+	case records <- record:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fileHTTPClient fetches csv and jsonl sources. Connecting and waiting for the response are bounded,
+// but reading the body is not, as large files are streamed for as long as they take.
+var fileHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	},
+}
+
+// htmlHTTPClient fetches html sources, which are read whole before parsing, so the whole request is bounded
+var htmlHTTPClient = &http.Client{
+	Transport: fileHTTPClient.Transport,
+	Timeout:   2 * time.Minute,
+}
+
+// httpGet performs a GET request bound to ctx, treating error status codes as failures
+// so an error page is never parsed as source data
+func httpGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 400 {
+		response.Body.Close()
+		return nil, fmt.Errorf("error response: %d", response.StatusCode)
+	}
+
+	return response, nil
+}
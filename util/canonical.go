@@ -0,0 +1,187 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// CanonicalJSON encodes v as RFC 8785 (JCS) canonical JSON: object members sorted by the UTF-16 code units
+// of their names, numbers in their shortest ECMAScript form and strings with minimal escaping, without whitespace.
+// The encoding of equal values is the same whatever their Go types, map iteration order or Go version.
+//
+// Go integers (e.g. database columns) are written with their exact digits. Up to 2^53 in magnitude this is
+// the RFC 8785 form; beyond it, where RFC 8785 would write the nearest float64 and lose the value, it is not,
+// so such an integer and the float64 nearest it encode differently.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	case string:
+		writeCanonicalString(buf, value)
+	case float64:
+		return writeCanonicalNumber(buf, value)
+	case float32:
+		return writeCanonicalNumber(buf, float64(value))
+	case int:
+		buf.WriteString(strconv.FormatInt(int64(value), 10))
+	case int8:
+		buf.WriteString(strconv.FormatInt(int64(value), 10))
+	case int16:
+		buf.WriteString(strconv.FormatInt(int64(value), 10))
+	case int32:
+		buf.WriteString(strconv.FormatInt(int64(value), 10))
+	case int64:
+		buf.WriteString(strconv.FormatInt(value, 10))
+	case uint:
+		buf.WriteString(strconv.FormatUint(uint64(value), 10))
+	case uint8:
+		buf.WriteString(strconv.FormatUint(uint64(value), 10))
+	case uint16:
+		buf.WriteString(strconv.FormatUint(uint64(value), 10))
+	case uint32:
+		buf.WriteString(strconv.FormatUint(uint64(value), 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(value, 10))
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, element := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, element); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, value[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		// Any other type (e.g. a named map type) is canonicalised through its standard JSON encoding
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("error encoding %T as canonical JSON: %w", value, err)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			return fmt.Errorf("error encoding %T as canonical JSON: %w", value, err)
+		}
+		return writeCanonical(buf, decoded)
+	}
+	return nil
+}
+
+// writeCanonicalNumber writes a number as ECMAScript's Number.prototype.toString would
+func writeCanonicalNumber(buf *bytes.Buffer, number float64) error {
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return fmt.Errorf("invalid JSON number %v", number)
+	}
+	if number == 0 {
+		buf.WriteByte('0') // including -0
+		return nil
+	}
+	if number < 0 {
+		buf.WriteByte('-')
+		number = -number
+	}
+
+	format := byte('e')
+	if number >= 1e-6 && number < 1e21 {
+		format = 'f'
+	}
+	formatted := strconv.FormatFloat(number, format, -1, 64)
+
+	// Go writes exponents with at least two digits ("1e+09"), ECMAScript with as few as needed ("1e+9")
+	if exponent := strings.IndexByte(formatted, 'e'); exponent > 0 && formatted[exponent+2] == '0' {
+		formatted = formatted[:exponent+2] + formatted[exponent+3:]
+	}
+	buf.WriteString(formatted)
+	return nil
+}
+
+// writeCanonicalString writes a JSON string escaping only quotes, backslashes and control characters
+func writeCanonicalString(buf *bytes.Buffer, text string) {
+	buf.WriteByte('"')
+	for _, r := range text {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				// Invalid UTF-8 is written as U+FFFD, as encoding/json does
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, as RFC 8785 sorts object member names
+func lessUTF16(a string, b string) bool {
+	if isASCII(a) && isASCII(b) {
+		return a < b
+	}
+	unitsA, unitsB := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(unitsA) && i < len(unitsB); i++ {
+		if unitsA[i] != unitsB[i] {
+			return unitsA[i] < unitsB[i]
+		}
+	}
+	return len(unitsA) < len(unitsB)
+}
+
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// Number vectors of RFC 8785 appendix B, as IEEE 754 bit patterns
+func TestCanonicalJSONNumbers(t *testing.T) {
+	tests := []struct {
+		bits uint64
+		want string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+
+	for _, test := range tests {
+		got, err := CanonicalJSON(math.Float64frombits(test.bits))
+		if err != nil {
+			t.Errorf("%016x: unexpected error: %v", test.bits, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%016x: got %s, want %s", test.bits, got, test.want)
+		}
+	}
+
+	for _, invalid := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := CanonicalJSON(invalid); err == nil {
+			t.Errorf("%v: expected an error", invalid)
+		}
+	}
+}
+
+func TestCanonicalJSONIntegers(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{0, "0"},
+		{int64(-42), "-42"},
+		{int32(1 << 30), "1073741824"},
+		{int64(1 << 53), "9007199254740992"},
+		{int64(1<<53 + 1), "9007199254740993"},
+		{int64(1 << 60), "1152921504606846976"},
+		{int64(math.MaxInt64), "9223372036854775807"},
+		{int64(math.MinInt64), "-9223372036854775808"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{uint8(255), "255"},
+	}
+
+	for _, test := range tests {
+		got, err := CanonicalJSON(test.value)
+		if err != nil {
+			t.Errorf("%T %v: unexpected error: %v", test.value, test.value, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%T %v: got %s, want %s", test.value, test.value, got, test.want)
+		}
+	}
+
+	// Integers a float64 holds exactly encode as the equal float64 does
+	for _, integer := range []int64{1, -1, 1e15, 1 << 53, -(1 << 53)} {
+		fromInt, _ := CanonicalJSON(integer)
+		fromFloat, _ := CanonicalJSON(float64(integer))
+		if string(fromInt) != string(fromFloat) {
+			t.Errorf("%d: int64 encodes as %s, float64 as %s", integer, fromInt, fromFloat)
+		}
+	}
+}
+
+func TestCanonicalJSONDocuments(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			// RFC 8785 section 3.2.2
+			name:  "values",
+			input: `{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001], "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/", "literals": [null, true, false]}`,
+			want:  `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		{
+			// RFC 8785 section 3.2.3: members sorted by UTF-16 code units, so the emoji's surrogates sort before U+FB33
+			name:  "sorting",
+			input: `{"\u20ac": "Euro Sign", "\r": "Carriage Return", "\ufb33": "Hebrew Letter Dalet With Dagesh", "1": "One", "\ud83d\ude00": "Emoji: Grinning Face", "\u0080": "Control", "\u00f6": "Latin Small Letter O With Diaeresis"}`,
+			want:  "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{
+			name:  "nested",
+			input: `{"b": [{"z": 1, "a": "x"}, []], "a": {}}`,
+			want:  `{"a":{},"b":[{"a":"x","z":1},[]]}`,
+		},
+	}
+
+	for _, test := range tests {
+		var input interface{}
+		if err := json.Unmarshal([]byte(test.input), &input); err != nil {
+			t.Fatalf("%s: invalid test input: %v", test.name, err)
+		}
+		got, err := CanonicalJSON(input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
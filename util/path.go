@@ -0,0 +1,298 @@
+package util
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path segments are object member names, except:
+//   - "*" (or "[*]") matches every element of an array, or every member of an object
+//   - "[n]" matches element n of an array, counting from the end when negative (e.g. "[-1]")
+//   - "**" matches any depth, including none
+//
+// Selectors can follow a member name in one segment (e.g. "line_items[*]" or "matrix[0][1]"). A segment is
+// always matched literally first when an object has a member of that name, so plain paths keep their meaning.
+
+type pathStepKind int
+
+const (
+	stepMember pathStepKind = iota
+	stepIndex
+	stepEach
+	stepDeep
+)
+
+type pathStep struct {
+	kind  pathStepKind
+	key   string
+	index int
+	// literal and width are set on the first step of a segment written with selectors: the segment as written
+	// and the number of steps it was parsed into
+	literal string
+	width   int
+}
+
+// pathMatch is a value matched by a path, with functions to replace or remove it where it was found
+type pathMatch struct {
+	value    interface{}
+	get      func() interface{}
+	set      func(interface{})
+	drop     func()
+	location string
+}
+
+var (
+	pathSelector = regexp.MustCompile(`\[(\*|-?[0-9]+)\]`)
+	pathSegment  = regexp.MustCompile(`^([^\[\]]*)((?:\[(?:\*|-?[0-9]+)\])+)$`)
+)
+
+// IsPlainPath reports whether every segment of path is a member name, without wildcards or array indices
+func IsPlainPath(path []string) bool {
+	for _, segment := range path {
+		if segment == "*" || segment == "**" || (strings.HasSuffix(segment, "]") && pathSegment.MatchString(segment)) {
+			return false
+		}
+	}
+	return true
+}
+
+// PathMatchesMany reports whether path can match more than one value, i.e. uses "*" or "**"
+func PathMatchesMany(path []string) bool {
+	for _, step := range parsePath(path) {
+		if step.kind == stepEach || step.kind == stepDeep {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePath(path []string) []pathStep {
+	steps := make([]pathStep, 0, len(path))
+	for _, segment := range path {
+		switch {
+		case segment == "*":
+			steps = append(steps, pathStep{kind: stepEach, literal: segment, width: 1})
+		case segment == "**":
+			// Consecutive "**" match the same depths as one, and would match values twice
+			if len(steps) > 0 && steps[len(steps)-1].kind == stepDeep {
+				continue
+			}
+			steps = append(steps, pathStep{kind: stepDeep, literal: segment, width: 1})
+		case pathSegment.MatchString(segment):
+			groups := pathSegment.FindStringSubmatch(segment)
+			segmentSteps := []pathStep{}
+			if groups[1] != "" {
+				segmentSteps = append(segmentSteps, pathStep{kind: stepMember, key: groups[1]})
+			}
+			for _, selector := range pathSelector.FindAllStringSubmatch(groups[2], -1) {
+				if selector[1] == "*" {
+					segmentSteps = append(segmentSteps, pathStep{kind: stepEach})
+					continue
+				}
+				index, _ := strconv.Atoi(selector[1])
+				segmentSteps = append(segmentSteps, pathStep{kind: stepIndex, index: index})
+			}
+			segmentSteps[0].literal, segmentSteps[0].width = segment, len(segmentSteps)
+			steps = append(steps, segmentSteps...)
+		default:
+			steps = append(steps, pathStep{kind: stepMember, key: segment})
+		}
+	}
+	return steps
+}
+
+// matchPath returns every value matched by path in input, in document order (object members by name).
+// Values reached more than once through "**" are returned once.
+func matchPath(path []string, input map[string]interface{}) []pathMatch {
+	root := pathMatch{
+		value: input,
+		get:   func() interface{} { return input },
+		set:   func(interface{}) {},
+		drop:  func() {},
+	}
+
+	var matches []pathMatch
+	seen := map[string]bool{}
+	walkPath(parsePath(path), root, func(match pathMatch) {
+		if !seen[match.location] {
+			seen[match.location] = true
+			matches = append(matches, match)
+		}
+	})
+	return matches
+}
+
+func walkPath(steps []pathStep, current pathMatch, emit func(pathMatch)) {
+	if len(steps) == 0 {
+		emit(current)
+		return
+	}
+	step := steps[0]
+
+	if object, ok := current.value.(map[string]interface{}); ok && step.literal != "" {
+		if _, exists := object[step.literal]; exists {
+			walkPath(steps[step.width:], memberMatch(object, step.literal, current.location), emit)
+			return
+		}
+	}
+
+	switch step.kind {
+	case stepMember:
+		if object, ok := current.value.(map[string]interface{}); ok {
+			if _, exists := object[step.key]; exists {
+				walkPath(steps[1:], memberMatch(object, step.key, current.location), emit)
+			}
+		}
+	case stepIndex:
+		if array, ok := current.value.([]interface{}); ok {
+			index := step.index
+			if index < 0 {
+				index += len(array)
+			}
+			if index >= 0 && index < len(array) {
+				walkPath(steps[1:], elementMatch(current, array, index), emit)
+			}
+		}
+	case stepEach:
+		for _, child := range childMatches(current) {
+			walkPath(steps[1:], child, emit)
+		}
+	case stepDeep:
+		walkPath(steps[1:], current, emit)
+		for _, child := range childMatches(current) {
+			walkPath(steps, child, emit)
+		}
+	}
+}
+
+func childMatches(current pathMatch) []pathMatch {
+	switch value := current.value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		children := make([]pathMatch, 0, len(keys))
+		for _, key := range keys {
+			children = append(children, memberMatch(value, key, current.location))
+		}
+		return children
+	case []interface{}:
+		children := make([]pathMatch, 0, len(value))
+		for index := range value {
+			children = append(children, elementMatch(current, value, index))
+		}
+		return children
+	default:
+		return nil
+	}
+}
+
+func memberMatch(object map[string]interface{}, key string, location string) pathMatch {
+	return pathMatch{
+		value:    object[key],
+		get:      func() interface{} { return object[key] },
+		set:      func(value interface{}) { object[key] = value },
+		drop:     func() { delete(object, key) },
+		location: location + "." + strconv.Quote(key),
+	}
+}
+
+// elementMatch reads the array through its parent when replacing or removing the element, as removing
+// earlier matches replaces the array
+func elementMatch(parent pathMatch, array []interface{}, index int) pathMatch {
+	return pathMatch{
+		value: array[index],
+		get: func() interface{} {
+			if current, ok := parent.get().([]interface{}); ok && index < len(current) {
+				return current[index]
+			}
+			return nil
+		},
+		set: func(value interface{}) {
+			if current, ok := parent.get().([]interface{}); ok && index < len(current) {
+				current[index] = value
+			}
+		},
+		drop: func() {
+			if current, ok := parent.get().([]interface{}); ok && index < len(current) {
+				parent.set(append(current[:index:index], current[index+1:]...))
+			}
+		},
+		location: parent.location + "[" + strconv.Itoa(index) + "]",
+	}
+}
+
+// GetValuesAtPath returns every non-null value matched by path in input
+func GetValuesAtPath(path []string, input map[string]interface{}) []interface{} {
+	var values []interface{}
+	for _, match := range matchPath(path, input) {
+		if match.value != nil {
+			values = append(values, match.value)
+		}
+	}
+	return values
+}
+
+// UpdateValuesAtPath replaces every non-null value matched by path in input with update's result,
+// returning the number of values replaced
+func UpdateValuesAtPath(path []string, input map[string]interface{}, update func(interface{}) (interface{}, error)) (int, error) {
+	updated := 0
+	for _, match := range matchPath(path, input) {
+		if match.value == nil {
+			continue
+		}
+		value, err := update(match.value)
+		if err != nil {
+			return updated, err
+		}
+		match.set(value)
+		updated++
+	}
+	return updated, nil
+}
+
+// PathReaches reports whether path matches the value at target, a path without wildcards, or an object or
+// array containing it, i.e. whether dropping or replacing the values matched by path would change target's value
+func PathReaches(path []string, target []string) bool {
+	steps := parsePath(target)
+	if len(path) == 0 || len(steps) == 0 || PathMatchesMany(target) {
+		return false
+	}
+
+	// Build the smallest input holding a value at target, then match path against it
+	var sample interface{} = struct{}{}
+	location := ""
+	for i := len(steps) - 1; i >= 0; i-- {
+		switch step := steps[i]; step.kind {
+		case stepMember:
+			sample = map[string]interface{}{step.key: sample}
+		case stepIndex:
+			length, index := step.index+1, step.index
+			if index < 0 {
+				length, index = -index, 0
+			}
+			array := make([]interface{}, length)
+			array[index] = sample
+			sample = array
+		}
+	}
+	input, ok := sample.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, match := range matchPath(target, input) {
+		location = match.location
+	}
+
+	for _, match := range matchPath(path, input) {
+		if match.location == location || strings.HasPrefix(location, match.location+".") || strings.HasPrefix(location, match.location+"[") {
+			return true
+		}
+	}
+	return false
+}
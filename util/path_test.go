@@ -0,0 +1,212 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const pathDocument = `{
+	"id": 1,
+	"tags": ["a", "b", "c"],
+	"contacts": [{"email": "x@example.com", "type": "work"}, {"email": "y@example.com"}],
+	"matrix": [[1, 2], [3, 4]],
+	"meta": {"updated_at": "t1", "nested": {"updated_at": "t2"}},
+	"a.b": "dotted",
+	"weird[0]": "literal index",
+	"items[*]": "literal wildcard",
+	"*": "literal star"
+}`
+
+func decodePathDocument(t *testing.T) map[string]interface{} {
+	t.Helper()
+	var document map[string]interface{}
+	if err := json.Unmarshal([]byte(pathDocument), &document); err != nil {
+		t.Fatalf("invalid test document: %v", err)
+	}
+	return document
+}
+
+func encodePathResult(t *testing.T, value interface{}) string {
+	t.Helper()
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(encoded)
+}
+
+func TestPathKinds(t *testing.T) {
+	tests := []struct {
+		path        []string
+		plain, many bool
+	}{
+		{[]string{"id"}, true, false},
+		{[]string{"meta", "updated_at"}, true, false},
+		{[]string{"a.b"}, true, false},
+		{[]string{"tags[1]"}, false, false},
+		{[]string{"tags", "[-1]"}, false, false},
+		{[]string{"matrix[0][1]"}, false, false},
+		{[]string{"tags", "*"}, false, true},
+		{[]string{"tags[*]"}, false, true},
+		{[]string{"**", "updated_at"}, false, true},
+		{[]string{"tags[x]"}, true, false}, // not a selector, so a member name
+	}
+
+	for _, test := range tests {
+		if got := IsPlainPath(test.path); got != test.plain {
+			t.Errorf("IsPlainPath(%q) = %v, want %v", test.path, got, test.plain)
+		}
+		if got := PathMatchesMany(test.path); got != test.many {
+			t.Errorf("PathMatchesMany(%q) = %v, want %v", test.path, got, test.many)
+		}
+	}
+}
+
+func TestGetValuesAtPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path []string
+		want string
+	}{
+		{"member", []string{"meta", "updated_at"}, `["t1"]`},
+		{"wildcard segment", []string{"tags", "*"}, `["a","b","c"]`},
+		{"wildcard selector", []string{"tags", "[*]"}, `["a","b","c"]`},
+		{"wildcard on member", []string{"tags[*]"}, `["a","b","c"]`},
+		{"wildcard over objects", []string{"meta", "*"}, `[{"updated_at":"t2"},"t1"]`},
+		{"index", []string{"tags", "[1]"}, `["b"]`},
+		{"index on member", []string{"tags[0]"}, `["a"]`},
+		{"negative index", []string{"tags[-1]"}, `["c"]`},
+		{"negative index at start", []string{"tags[-3]"}, `["a"]`},
+		{"index out of range", []string{"tags[3]"}, `null`},
+		{"negative index out of range", []string{"tags[-4]"}, `null`},
+		{"index of an object", []string{"meta[0]"}, `null`},
+		{"wildcard then member", []string{"contacts[*]", "email"}, `["x@example.com","y@example.com"]`},
+		{"wildcard then missing member", []string{"contacts", "*", "type"}, `["work"]`},
+		{"chained selectors", []string{"matrix[1][0]"}, `[3]`},
+		{"wildcard then negative index", []string{"matrix[*][-1]"}, `[2,4]`},
+		{"deep", []string{"**", "updated_at"}, `["t1","t2"]`},
+		{"deep below member", []string{"meta", "**", "updated_at"}, `["t1","t2"]`},
+		{"repeated deep", []string{"**", "**", "updated_at"}, `["t1","t2"]`},
+		{"deep into arrays", []string{"**", "email"}, `["x@example.com","y@example.com"]`},
+		{"dotted member", []string{"a.b"}, `["dotted"]`},
+		{"dots are not separators", []string{"a", "b"}, `null`},
+		{"literal member first: index", []string{"weird[0]"}, `["literal index"]`},
+		{"literal member first: wildcard selector", []string{"items[*]"}, `["literal wildcard"]`},
+		{"literal member first: wildcard", []string{"*"}, `["literal star"]`},
+	}
+
+	for _, test := range tests {
+		document := decodePathDocument(t)
+		if got := encodePathResult(t, GetValuesAtPath(test.path, document)); got != test.want {
+			t.Errorf("%s: GetValuesAtPath(%q) = %s, want %s", test.name, test.path, got, test.want)
+		}
+	}
+}
+
+func TestGetValueAtPath(t *testing.T) {
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"id"}, `1`},
+		{[]string{"tags[-1]"}, `"c"`},
+		{[]string{"tags[*]"}, `["a","b","c"]`}, // paths matching many values return them all
+		{[]string{"tags[9]"}, `null`},
+		{[]string{"**", "missing"}, `null`},
+	}
+
+	for _, test := range tests {
+		document := decodePathDocument(t)
+		if got := encodePathResult(t, GetValueAtPath(test.path, document)); got != test.want {
+			t.Errorf("GetValueAtPath(%q) = %s, want %s", test.path, got, test.want)
+		}
+	}
+}
+
+func TestUpdateValuesAtPath(t *testing.T) {
+	tests := []struct {
+		path    []string
+		updated int
+		field   string
+		want    string
+	}{
+		{[]string{"contacts[*]", "email"}, 2, "contacts", `[{"email":"masked","type":"work"},{"email":"masked"}]`},
+		{[]string{"tags[-1]"}, 1, "tags", `["a","b","masked"]`},
+		{[]string{"**", "updated_at"}, 2, "meta", `{"nested":{"updated_at":"masked"},"updated_at":"masked"}`},
+		{[]string{"tags[5]"}, 0, "tags", `["a","b","c"]`},
+	}
+
+	for _, test := range tests {
+		document := decodePathDocument(t)
+		updated, err := UpdateValuesAtPath(test.path, document, func(interface{}) (interface{}, error) { return "masked", nil })
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", test.path, err)
+		}
+		if updated != test.updated {
+			t.Errorf("%q: updated %d values, want %d", test.path, updated, test.updated)
+		}
+		if got := encodePathResult(t, document[test.field]); got != test.want {
+			t.Errorf("%q: %s is %s, want %s", test.path, test.field, got, test.want)
+		}
+	}
+}
+
+func TestDropFieldAtPath(t *testing.T) {
+	tests := []struct {
+		path  []string
+		field string
+		want  string
+	}{
+		{[]string{"tags[0]"}, "tags", `["b","c"]`},
+		{[]string{"tags[-1]"}, "tags", `["a","b"]`},
+		{[]string{"tags", "*"}, "tags", `[]`},
+		{[]string{"contacts[*]", "type"}, "contacts", `[{"email":"x@example.com"},{"email":"y@example.com"}]`},
+		{[]string{"matrix[*][0]"}, "matrix", `[[2],[4]]`},
+		{[]string{"**", "updated_at"}, "meta", `{"nested":{}}`},
+		{[]string{"meta", "nested"}, "meta", `{"updated_at":"t1"}`},
+		{[]string{"weird[0]"}, "weird[0]", `null`},
+		{[]string{"a.b"}, "a.b", `null`},
+		{[]string{"tags[7]"}, "tags", `["a","b","c"]`},
+	}
+
+	for _, test := range tests {
+		document := decodePathDocument(t)
+		if err := DropFieldAtPath(test.path, document); err != nil {
+			t.Fatalf("%q: unexpected error: %v", test.path, err)
+		}
+		if got := encodePathResult(t, document[test.field]); got != test.want {
+			t.Errorf("%q: %s is %s, want %s", test.path, test.field, got, test.want)
+		}
+	}
+}
+
+func TestPathReaches(t *testing.T) {
+	tests := []struct {
+		path, target []string
+		want         bool
+	}{
+		{[]string{"updated_at"}, []string{"updated_at"}, true},
+		{[]string{"meta", "updated_at"}, []string{"meta", "updated_at"}, true},
+		{[]string{"meta"}, []string{"meta", "updated_at"}, true},
+		{[]string{"*"}, []string{"meta", "updated_at"}, true},
+		{[]string{"*", "updated_at"}, []string{"meta", "updated_at"}, true},
+		{[]string{"**", "updated_at"}, []string{"meta", "updated_at"}, true},
+		{[]string{"**"}, []string{"meta", "updated_at"}, true},
+		{[]string{"items[*]", "ts"}, []string{"items[-1]", "ts"}, true},
+		{[]string{"items"}, []string{"items[0]", "ts"}, true},
+		{[]string{"updated_at"}, []string{"meta", "updated_at"}, false},
+		{[]string{"meta", "created_at"}, []string{"meta", "updated_at"}, false},
+		{[]string{"meta", "updated_at", "value"}, []string{"meta", "updated_at"}, false},
+		{[]string{"meta.updated_at"}, []string{"meta", "updated_at"}, false},
+		{[]string{"meta", "updated_at"}, []string{"meta.updated_at"}, false},
+		{[]string{"meta.updated_at"}, []string{"meta.updated_at"}, true},
+		{[]string{"items[1]", "ts"}, []string{"items[0]", "ts"}, false},
+		{[]string{"metadata"}, []string{"meta"}, false},
+	}
+
+	for _, test := range tests {
+		if got := PathReaches(test.path, test.target); got != test.want {
+			t.Errorf("PathReaches(%q, %q) = %v, want %v", test.path, test.target, got, test.want)
+		}
+	}
+}
@@ -3,9 +3,11 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -25,6 +27,9 @@ func NowTimestamp() string {
 	return FormatTimestamp(time.Now())
 }
 
+// Base64 is binary data encoded as standard base64, so schema discovery can declare its contentEncoding
+type Base64 string
+
 func IsTimestampString(value string) bool {
 	_, err := time.Parse(TimestampFormat, value)
 	return err == nil
@@ -51,6 +56,36 @@ func ToKeyString(v interface{}) string {
 	}
 }
 
+// NaturalKeyString converts a natural key to its bookmark key. Composite keys (arrays of components)
+// are JSON encoded, so components containing separators never collide (["a|b", "c"] vs ["a", "b|c"]).
+func NaturalKeyString(v interface{}) string {
+	if components, ok := v.([]interface{}); ok {
+		if encoded, err := json.Marshal(components); err == nil {
+			return string(encoded)
+		}
+	}
+	return ToKeyString(v)
+}
+
+// SyncWriter serialises writes so concurrent producers never interleave partial messages
+type SyncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewSyncWriter(w io.Writer) *SyncWriter {
+	if sw, ok := w.(*SyncWriter); ok {
+		return sw
+	}
+	return &SyncWriter{w: w}
+}
+
+func (sw *SyncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
+}
+
 func WriteJSON(fileName string, data interface{}) error {
 	file, err := os.Create(fileName)
 	if err != nil {
@@ -63,7 +98,22 @@ func WriteJSON(fileName string, data interface{}) error {
 	return encoder.Encode(data)
 }
 
+// GetValueAtPath returns the value at path in input, or nil. Paths using "*" or "**" (see path.go) return
+// an array of every non-null value they match, or nil when they match none.
 func GetValueAtPath(path []string, input map[string]interface{}) interface{} {
+	if !IsPlainPath(path) {
+		if PathMatchesMany(path) {
+			if values := GetValuesAtPath(path, input); len(values) > 0 {
+				return values
+			}
+			return nil
+		}
+		if matches := matchPath(path, input); len(matches) > 0 {
+			return matches[0].value
+		}
+		return nil
+	}
+
 	if len(path) > 0 {
 		if check, ok := input[path[0]]; !ok || check == nil {
 			return nil
@@ -83,7 +133,16 @@ func GetValueAtPath(path []string, input map[string]interface{}) interface{} {
 	}
 }
 
+// SetValueAtPath sets the value at path in input, creating missing objects along a plain path.
+// Paths with wildcards or array indices set every value they match, and create nothing.
 func SetValueAtPath(path []string, input map[string]interface{}, value interface{}) {
+	if !IsPlainPath(path) {
+		for _, match := range matchPath(path, input) {
+			match.set(value)
+		}
+		return
+	}
+
 	if len(path) == 1 {
 		input[path[0]] = value
 		return
@@ -99,11 +158,26 @@ func SetValueAtPath(path []string, input map[string]interface{}, value interface
 	SetValueAtPath(path, input[key].(map[string]interface{}), value)
 }
 
+// DropFieldAtPath removes the field at path from input. Paths with wildcards or array indices remove
+// every value they match, including array elements.
 func DropFieldAtPath(path []string, input map[string]interface{}) error {
 	if len(path) == 0 {
 		return nil
 	}
 
+	if !IsPlainPath(path) {
+		matches := matchPath(path, input)
+		if len(matches) == 0 {
+			log.WithField("drop_field_path", path).Warn("drop field path not found; skipping")
+			return nil
+		}
+		// Removing in reverse keeps the indices of earlier array elements, and removes nested matches before their parents
+		for i := len(matches) - 1; i >= 0; i-- {
+			matches[i].drop()
+		}
+		return nil
+	}
+
 	var currentMap = input
 	for i := 0; i < len(path)-1; i++ {
 		key := path[i]
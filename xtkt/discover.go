@@ -0,0 +1,84 @@
+package xtkt
+
+import (
+	"context"
+
+	"github.com/5amCurfew/xtkt/models"
+	util "github.com/5amCurfew/xtkt/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Discover runs catalog discovery mode.
+func (r *Runner) Discover(ctx context.Context) error {
+	if err := r.initialiseRun(true); err != nil {
+		return err
+	}
+	defer r.State.StopBookmarkUpdates()
+
+	// Stop the source and pipeline goroutines if the run returns before they finish
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r.startRecordStream(ctx)
+
+	if err := r.runDiscoveryMode(); err != nil {
+		return err
+	}
+
+	if err := r.finaliseExtraction(); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// runDiscoveryMode runs catalog discovery and validates the schema
+func (r *Runner) runDiscoveryMode() error {
+	if err := r.discoverCatalog(); err != nil {
+		return err
+	}
+
+	if len(r.Catalog.Schema) == 0 {
+		return r.logAndReturnError("discovery produced an empty schema", nil)
+	}
+
+	catalog := r.schemaCatalog()
+	if err := catalog.Message(r.output); err != nil {
+		return r.logAndWrapError("discovery schema message generation failed", err, nil)
+	}
+
+	return nil
+}
+
+// discoverCatalog infers and updates the catalog based on processed records
+func (r *Runner) discoverCatalog() error {
+	var catalogSchema models.Schema
+	if err := catalogSchema.Create(r.Catalog.Schema); err != nil {
+		return r.logAndWrapError("discovery schema initialisation failed", err, nil)
+	}
+
+	for record := range r.pipeline.ResultChan {
+		// Update the schema with the new record
+		if err := catalogSchema.Merge(record.ToMap()); err != nil {
+			log.WithFields(log.Fields{
+				"_sdc_natural_key": record["_sdc_natural_key"],
+				"error":            err,
+			}).Warn("record schema merge failed during discovery")
+			continue
+		}
+	}
+
+	// A partial extraction must not overwrite the catalog with a schema inferred from some records only
+	if err := r.checkSource(); err != nil {
+		return err
+	}
+
+	// Update the catalog's schema with the merged schema
+	r.Catalog.Schema = catalogSchema.ToMap()
+	r.Catalog.SchemaDiscoveredAt = util.NowTimestamp()
+	if err := r.Catalog.Update(); err != nil {
+		return r.logAndWrapError("derived catalog update failed", err, nil)
+	}
+
+	return nil
+}
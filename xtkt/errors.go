@@ -0,0 +1,10 @@
+package xtkt
+
+import "errors"
+
+// ErrConfig is wrapped by errors caused by an invalid or unsupported stream configuration
+var ErrConfig = errors.New("config error")
+
+// ErrSource is wrapped by errors returned when the source fails part-way through a run.
+// State and catalog files are not advanced by a run failing with ErrSource.
+var ErrSource = errors.New("source error")
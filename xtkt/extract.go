@@ -0,0 +1,170 @@
+package xtkt
+
+import (
+	"context"
+	"sort"
+
+	"github.com/5amCurfew/xtkt/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// Extract runs the default extraction flow.
+func (r *Runner) Extract(ctx context.Context) error {
+	if err := r.initialiseRun(false); err != nil {
+		return err
+	}
+	defer r.State.StopBookmarkUpdates()
+
+	// Stop the source and pipeline goroutines if the run returns before they finish
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := r.ensureCatalogSchemaAvailable(); err != nil {
+		return err
+	}
+
+	r.startRecordStream(ctx)
+
+	if err := r.processRecords(); err != nil {
+		return err
+	}
+
+	// A partial extraction must not advance state, otherwise the missing records would never be retried
+	if err := r.checkSource(); err != nil {
+		r.State.StopBookmarkUpdates()
+		r.Metrics.Complete(r.pipeline.Metrics)
+		log.WithFields(log.Fields{"stream": r.Config.StreamName, "metrics": r.Metrics}).Error("source failed; state not updated")
+		return err
+	}
+
+	if err := r.finaliseExtraction(); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// schemaCatalog returns the catalog as sent in SCHEMA messages, declaring _sdc_deleted_at when tombstones may be emitted
+func (r *Runner) schemaCatalog() models.StreamCatalog {
+	if r.Config.Records.DeletionDetection.Enabled {
+		return r.Catalog.WithDeletedAt()
+	}
+	return r.Catalog
+}
+
+// processRecords emits the records validated by the pipeline
+func (r *Runner) processRecords() error {
+	catalog := r.schemaCatalog()
+	if err := catalog.Message(r.output); err != nil {
+		return r.logAndWrapError("schema message generation failed", err, nil)
+	}
+
+	for record := range r.pipeline.ResultChan {
+		if err := record.Message(r.output, r.Config.StreamName); err != nil {
+			return r.logAndWrapError("record message generation failed", err, log.Fields{
+				"_sdc_natural_key": record["_sdc_natural_key"],
+			})
+		}
+
+		// Only records that pass schema validation should advance state.
+		if !r.discover {
+			r.State.QueueBookmarkUpdate(record.ToMap(), true)
+		}
+
+		r.Metrics.Emitted += 1
+
+		if interval := r.Config.StateInterval; interval > 0 && r.Metrics.Emitted%uint64(interval) == 0 {
+			if err := r.checkpointState(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// finaliseExtraction writes state, calculates metrics, and logs results
+func (r *Runner) finaliseExtraction() error {
+	r.State.StopBookmarkUpdates()
+
+	if !r.discover && r.Config.Records.DeletionDetection.Enabled {
+		if err := r.emitDeletions(); err != nil {
+			return err
+		}
+	}
+
+	if !r.discover && len(r.Config.Records.ReplicationKeyPath) > 0 {
+		r.advanceReplicationKey()
+	}
+
+	// With ConfirmedState the state is read-only, and only CommitState writes it
+	if err := r.State.Update(); err != nil {
+		return r.logAndWrapError("state update failed", err, nil)
+	}
+
+	if err := r.checkpointState(); err != nil {
+		return err
+	}
+
+	r.Metrics.Complete(r.pipeline.Metrics)
+
+	log.WithFields(log.Fields{"stream": r.Config.StreamName, "metrics": r.Metrics}).Info("execution metrics")
+	return nil
+}
+
+// advanceReplicationKey moves the watermark on to the latest replication key emitted or seen this run.
+// Records that failed transformation or validation may be older than it, so a run with failures keeps
+// the previous watermark and the next incremental run requests them again.
+func (r *Runner) advanceReplicationKey() {
+	if r.pipeline.Metrics.TransformFailed > 0 || r.pipeline.Metrics.SchemaValidationFailed > 0 {
+		log.WithFields(log.Fields{
+			"transform_failed":         r.pipeline.Metrics.TransformFailed,
+			"schema_validation_failed": r.pipeline.Metrics.SchemaValidationFailed,
+		}).Warn("incomplete extraction; replication key not advanced")
+		return
+	}
+
+	r.State.AdvanceReplicationKey()
+}
+
+// emitDeletions emits tombstone records for natural keys not seen in this run beyond the grace period.
+// Detection is skipped whenever the run may not have observed every source record.
+func (r *Runner) emitDeletions() error {
+	fields := log.Fields{
+		"transform_failed":         r.pipeline.Metrics.TransformFailed,
+		"schema_validation_failed": r.pipeline.Metrics.SchemaValidationFailed,
+	}
+	if r.pipeline.Metrics.TransformFailed > 0 || r.pipeline.Metrics.SchemaValidationFailed > 0 {
+		log.WithFields(fields).Warn("incomplete extraction; skipping deletion detection")
+		return nil
+	}
+
+	deleted, err := r.State.DetectDeletions(r.Config.Records.DeletionDetection.GracePeriod)
+	if err != nil {
+		return r.logAndWrapError("deletion detection failed", err, nil)
+	}
+
+	// Tombstones are emitted in natural key order, so runs are reproducible
+	keys := make([]string, 0, len(deleted))
+	for key := range deleted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var tombstone models.Record
+		if err := tombstone.CreateTombstone(r.Catalog.NaturalKeyFromBookmark(key), deleted[key]); err != nil {
+			return r.logAndWrapError("tombstone generation failed", err, log.Fields{
+				"_sdc_natural_key": key,
+			})
+		}
+		if err := tombstone.Message(r.output, r.Config.StreamName); err != nil {
+			return r.logAndWrapError("tombstone message generation failed", err, log.Fields{
+				"_sdc_natural_key": key,
+			})
+		}
+		r.Metrics.Deleted += 1
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package xtkt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/5amCurfew/xtkt/models"
+)
+
+// inTempDir runs the test in a new directory, as runners read and write <stream_name>_state.json and _catalog.json
+func inTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+// runnerMessages decodes the Singer messages a runner wrote, by type
+func runnerMessages(t *testing.T, output *bytes.Buffer) map[string][]map[string]interface{} {
+	t.Helper()
+	messages := map[string][]map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var message map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("invalid message %q: %v", line, err)
+		}
+		messages[message["type"].(string)] = append(messages[message["type"].(string)], message)
+	}
+	output.Reset()
+	return messages
+}
+
+func TestExtractEmitsTombstones(t *testing.T) {
+	dir := inTempDir(t)
+	source := filepath.Join(dir, "users.jsonl")
+	writeSource := func(ids ...string) {
+		var lines []string
+		for _, id := range ids {
+			lines = append(lines, `{"id": `+id+`, "name": "user `+id+`"}`)
+		}
+		if err := os.WriteFile(source, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	config := models.StreamConfig{StreamName: "users", SourceType: "jsonl", URL: source}
+	config.Records.UniqueKeyPath = []string{"id"}
+	config.Records.DeletionDetection = models.DeletionDetectionConfig{Enabled: true, GracePeriod: 1}
+
+	var output bytes.Buffer
+	runner := NewRunner(config, &output)
+
+	writeSource("1", "2", "3")
+	if err := runner.Discover(context.Background()); err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	output.Reset()
+
+	tests := []struct {
+		ids        []string
+		records    int
+		tombstones []string
+	}{
+		{[]string{"1", "2", "3"}, 3, nil},
+		{[]string{"1", "3"}, 0, nil}, // within the grace period
+		{[]string{"1"}, 0, []string{"2"}},
+		{[]string{"1"}, 0, []string{"3"}}, // each deletion is emitted once
+		{[]string{"1", "2"}, 1, nil},      // restored
+	}
+
+	for run, test := range tests {
+		writeSource(test.ids...)
+		if err := runner.Extract(context.Background()); err != nil {
+			t.Fatalf("run %d: unexpected error: %v", run+1, err)
+		}
+
+		var records int
+		var tombstones []string
+		for _, message := range runnerMessages(t, &output)["RECORD"] {
+			record := message["record"].(map[string]interface{})
+			if record["_sdc_deleted_at"] == nil {
+				records++
+				continue
+			}
+			key, _ := json.Marshal(record["_sdc_natural_key"])
+			tombstones = append(tombstones, string(key))
+		}
+		sort.Strings(tombstones)
+
+		if records != test.records || strings.Join(tombstones, ",") != strings.Join(test.tombstones, ",") {
+			t.Errorf("run %d: got %d records and tombstones %v, want %d records and tombstones %v", run+1, records, tombstones, test.records, test.tombstones)
+		}
+	}
+}
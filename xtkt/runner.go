@@ -0,0 +1,190 @@
+// Package xtkt runs Singer.io extractions for a stream from within a Go program.
+// The xtkt command line interface is built on the Runner in this package.
+package xtkt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/5amCurfew/xtkt/lib"
+	"github.com/5amCurfew/xtkt/models"
+	"github.com/5amCurfew/xtkt/sources"
+	log "github.com/sirupsen/logrus"
+)
+
+// Runner extracts a single stream, owning its pipeline channels, state, catalog and metrics.
+// Singer messages are written to the Runner's output. A Runner may be run repeatedly,
+// each run re-reading state and catalog, but must not be run concurrently with itself.
+type Runner struct {
+	Config      models.StreamConfig
+	State       models.StreamState
+	Catalog     models.StreamCatalog
+	Metrics     lib.ExecutionMetric
+	FullRefresh bool // extract all records rather than only new or modified records
+
+	// InputState and InputCatalog, when set, are used instead of the <stream_name>_state.json
+	// and <stream_name>_catalog.json files (e.g. as supplied by a Singer runner via --state and --catalog).
+	// InputCatalog is ignored during discovery, which always merges into the catalog file.
+	InputState   *models.StreamState
+	InputCatalog *models.StreamCatalog
+
+	// TapState is the Singer state sent in STATE messages every config.StateInterval records and at the end of
+	// a run. Runners sharing output should share a TapState so each STATE message covers every stream.
+	TapState *models.TapState
+
+	// ConfirmedState leaves <stream_name>_state.json untouched; it is only advanced from the state a target
+	// echoes once records are loaded (see cmd.CommitState), so a failed load never moves bookmarks on.
+	ConfirmedState bool
+
+	output    io.Writer
+	pipeline  *lib.Pipeline
+	discover  bool
+	sourceErr error // safe to read once the pipeline's ResultChan is drained
+}
+
+// NewRunner creates a Runner for the stream described by config, writing Singer messages to output
+func NewRunner(config models.StreamConfig, output io.Writer) *Runner {
+	return &Runner{
+		Config: config,
+		output: output,
+	}
+}
+
+// logAndWrapError logs message with the stream, err and fields, and returns err wrapped with message
+func (r *Runner) logAndWrapError(message string, err error, fields log.Fields) error {
+	log.WithFields(fields).WithFields(log.Fields{"stream": r.Config.StreamName, "error": err}).Error(message)
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// logAndReturnError logs message with the stream and fields, and returns it as an error
+func (r *Runner) logAndReturnError(message string, fields log.Fields) error {
+	log.WithFields(fields).WithField("stream", r.Config.StreamName).Error(message)
+	return errors.New(message)
+}
+
+func (r *Runner) initialiseRun(discover bool) error {
+	if err := r.Config.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+
+	r.discover = discover
+	r.sourceErr = nil
+	r.State = models.StreamState{ReadOnly: r.ConfirmedState}
+	r.Catalog = models.StreamCatalog{}
+	r.Metrics = lib.NewExecutionMetric()
+
+	// Each run paginates and authenticates on its own copy of the config
+	config := r.Config
+	r.pipeline = lib.NewPipeline(&config, &r.State, &r.Catalog, r.FullRefresh, discover)
+	r.Metrics.Concurrency = r.pipeline.Concurrency
+
+	// initialise state and catalog files, unless supplied by the caller
+	stateSource := []interface{}{r.Config.StreamName}
+	if r.InputState != nil {
+		stateSource = append(stateSource, r.InputState)
+	}
+	if err := r.State.Create(stateSource...); err != nil {
+		return r.logAndWrapError("state initialisation failed", err, log.Fields{
+			"discover": discover,
+			"refresh":  r.FullRefresh,
+		})
+	}
+
+	// Mark the start of this extraction run
+	r.State.StartExtraction()
+	r.State.ReplicationKeyPath = r.Config.Records.ReplicationKeyPath
+
+	// Incremental runs only request records changed since the watermark of the last complete run
+	if !discover && !r.FullRefresh {
+		if err := config.ApplyReplicationKeyValue(r.State.Bookmark.ReplicationKeyValue); err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+	}
+
+	catalogSource := []interface{}{r.Config.StreamName}
+	if r.InputCatalog != nil && !discover {
+		catalogSource = append(catalogSource, r.InputCatalog)
+	}
+	if err := r.Catalog.Create(catalogSource...); err != nil {
+		return r.logAndWrapError("catalog initialisation failed", err, log.Fields{
+			"discover": discover,
+			"refresh":  r.FullRefresh,
+		})
+	}
+
+	if r.TapState == nil {
+		r.TapState = &models.TapState{}
+	}
+
+	r.State.StartBookmarkUpdates()
+
+	return nil
+}
+
+// checkpointState writes a STATE message with the bookmarks of every record emitted so far by every stream
+// sharing TapState (never in discovery)
+func (r *Runner) checkpointState() error {
+	if r.discover {
+		return nil
+	}
+
+	if err := r.TapState.Checkpoint(r.output, r.State.Snapshot()); err != nil {
+		return r.logAndWrapError("state message generation failed", err, nil)
+	}
+	return nil
+}
+
+// checkSource returns an ErrSource error if the source failed; it must only be called once ResultChan is drained
+func (r *Runner) checkSource() error {
+	if r.sourceErr != nil {
+		return fmt.Errorf("%w: %w", ErrSource, r.sourceErr)
+	}
+	return nil
+}
+
+func (r *Runner) ensureCatalogSchemaAvailable() error {
+	if len(r.Catalog.Schema) == 0 {
+		return r.logAndReturnError("catalog schema unavailable; this can be generated using discovery mode", nil)
+	}
+
+	// Compiled once up front, so an invalid catalog fails the run before any record is extracted
+	if err := r.Catalog.Compile(); err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+
+	return nil
+}
+
+// startRecordStream initiates the goroutine to extract and transform records
+func (r *Runner) startRecordStream(ctx context.Context) {
+	go func() {
+		defer close(r.pipeline.ResultChan)
+		log.WithFields(log.Fields{
+			"stream":      r.Config.StreamName,
+			"source_type": r.Config.SourceType,
+			"url":         r.Config.URL,
+		}).Info("starting record extraction")
+
+		switch r.Config.SourceType {
+		case "csv":
+			r.sourceErr = r.pipeline.ExtractRecords(ctx, sources.StreamCSVRecords)
+		case "db":
+			r.sourceErr = r.pipeline.ExtractRecords(ctx, sources.StreamDBRecords)
+		case "graphql":
+			r.sourceErr = r.pipeline.ExtractRecords(ctx, sources.StreamGraphQLRecords)
+		case "html":
+			r.sourceErr = r.pipeline.ExtractRecords(ctx, sources.StreamHTMLRecords)
+		case "jsonl":
+			r.sourceErr = r.pipeline.ExtractRecords(ctx, sources.StreamJSONLRecords)
+		case "rest":
+			r.sourceErr = r.pipeline.ExtractRecords(ctx, sources.StreamRESTRecords)
+		default:
+			// Unreachable once the config is validated, but never report an unknown source as empty
+			r.sourceErr = fmt.Errorf("unsupported source type %q", r.Config.SourceType)
+		}
+
+		r.pipeline.ProcessingWG.Wait()
+	}()
+}